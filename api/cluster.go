@@ -2,18 +2,19 @@ package api
 
 import (
 	"context"
-	"errors"
-	"net/http"
+	"fmt"
 	"reflect"
 	"slices"
-	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/getseabird/seabird/internal/pubsub"
 	"github.com/getseabird/seabird/internal/util"
 	"github.com/zmwangx/debounce"
 	appsv1 "k8s.io/api/apps/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	eventsv1 "k8s.io/api/events/v1"
@@ -29,7 +30,6 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/dynamic/dynamicinformer"
 	"k8s.io/client-go/informers"
@@ -46,29 +46,58 @@ import (
 type Cluster struct {
 	client.Client
 	*kubernetes.Clientset
-	Config                 *rest.Config
 	ClusterPreferences     pubsub.Property[ClusterPreferences]
 	Metrics                *Metrics
 	Events                 *Events
+	PortForwards           *PortForwards
 	RESTMapper             meta.RESTMapper
 	DynamicClient          *dynamic.DynamicClient
 	Scheme                 *runtime.Scheme
 	Encoder                *Encoder
-	Resources              []metav1.APIResource
+	Resources              pubsub.Property[[]metav1.APIResource]
+	Report                 pubsub.Property[ConnectionReport]
+	Connection             pubsub.Property[Connection]
+	Namespaces             []string
 	ctx                    context.Context
+	cancel                 context.CancelFunc
+	mapper                 *reloadableRESTMapper
+	rules                  []authorizationv1.ResourceRule
+	credentials            *credentials
+	config                 atomic.Pointer[rest.Config]
+	reportMutex            sync.Mutex
+	watchErrors            map[schema.GroupVersionResource]InformerFailure
 	informerFactory        informers.SharedInformerFactory
 	dynamicInformerFactory dynamicinformer.DynamicSharedInformerFactory
 	sharedInformers        map[schema.GroupVersionResource]informers.GenericInformer
+	informerHandlers       map[schema.GroupVersionResource][]*informerHandler
+	informerCtx            context.Context
+	informerCancel         context.CancelFunc
+	informerMutex          sync.Mutex
+}
+
+// informerHandler is an event handler added with AddInformerEventHandler. It's
+// kept to move it to the new informer when informers are restarted.
+type informerHandler struct {
+	handler      cache.ResourceEventHandler
+	registration cache.ResourceEventHandlerRegistration
 }
 
 func NewCluster(ctx context.Context, clusterPrefs pubsub.Property[ClusterPreferences]) (*Cluster, error) {
+	prefs := clusterPrefs.Value()
+	proxy, err := prefs.Proxy()
+	if err != nil {
+		return nil, err
+	}
 	config := &rest.Config{
 		Host:            clusterPrefs.Value().Host,
 		BearerToken:     clusterPrefs.Value().BearerToken,
 		TLSClientConfig: clusterPrefs.Value().TLS,
 		ExecProvider:    clusterPrefs.Value().Exec,
-		Proxy:           http.ProxyFromEnvironment,
+		Impersonate:     clusterPrefs.Value().Impersonate,
+		Proxy:           proxy,
 	}
+	credentials := &credentials{}
+	credentials.wrap(config)
 
 	scheme := runtime.NewScheme()
 	corev1.AddToScheme(scheme)
@@ -81,13 +110,6 @@ func NewCluster(ctx context.Context, clusterPrefs pubsub.Property[ClusterPrefere
 	batchv1.AddToScheme(scheme)
 	metricsv1beta1.AddToScheme(scheme)
 
-	rclient, err := client.New(config, client.Options{
-		Scheme: scheme,
-	})
-	if err != nil {
-		return nil, err
-	}
-
 	dynamicClient, err := dynamic.NewForConfig(config)
 	if err != nil {
 		return nil, err
@@ -98,63 +120,55 @@ func NewCluster(ctx context.Context, clusterPrefs pubsub.Property[ClusterPrefere
 		return nil, err
 	}
 
-	res, err := restmapper.GetAPIGroupResources(clientset.Discovery())
-	if err != nil {
-		return nil, err
+	namespaces := detectNamespaces(ctx, clientset, prefs)
+	var rules []authorizationv1.ResourceRule
+	if len(namespaces) > 0 {
+		rules = listRules(ctx, clientset, namespaces)
 	}
-	mapper := restmapper.NewDiscoveryRESTMapper(res)
-
-	informerFactory := informers.NewSharedInformerFactory(clientset, time.Hour)
-	informerFactory.Start(ctx.Done())
-	dynamicInformerFactory := dynamicinformer.NewDynamicSharedInformerFactory(dynamicClient, time.Hour)
-	dynamicInformerFactory.Start(ctx.Done())
 
-	var resources []metav1.APIResource
-	preferredResources, err := clientset.Discovery().ServerPreferredResources()
-	if err != nil {
-		var groupDiscoveryFailed *discovery.ErrGroupDiscoveryFailed
-		if errors.As(err, &groupDiscoveryFailed) {
-			for api, err := range groupDiscoveryFailed.Groups {
-				// TODO display as toast
-				klog.Infof("group discovery failed for '%s': %s", api.String(), err.Error())
-			}
-		} else {
-			return nil, err
-		}
-	}
-	for _, list := range preferredResources {
-		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+	var failedGroups map[schema.GroupVersion]error
+	discovered, cached := loadDiscoveryCache(config.Host)
+	if !cached {
+		discovered, failedGroups, err = discover(clientset.Discovery())
 		if err != nil {
 			return nil, err
 		}
-		for _, res := range list.APIResources {
-			if res.Group == "" {
-				res.Group = gv.Group
-			}
-			if res.Version == "" {
-				res.Version = gv.Version
+		if len(failedGroups) == 0 {
+			if err := discovered.save(config.Host); err != nil {
+				klog.Infof("discovery cache: %s", err)
 			}
-
-			if !slices.Contains(res.Verbs, "get") || !slices.Contains(res.Verbs, "list") {
-				continue
-			}
-
-			resources = append(resources, res)
 		}
 	}
+	mapper := newReloadableRESTMapper(restmapper.NewDiscoveryRESTMapper(discovered.Groups))
+
+	rclient, err := client.New(config, client.Options{
+		Scheme: scheme,
+		Mapper: mapper,
+	})
+	if err != nil {
+		return nil, err
+	}
 
-	metrics, err := newMetrics(ctx, rclient, resources)
+	resources, err := apiResources(discovered.Groups)
 	if err != nil {
-		klog.Infof("metrics disabled: %s", err.Error())
+		return nil, err
 	}
+	resources = filterListable(rules, resources)
 
-	sort.Slice(resources, func(i, j int) bool {
-		return resources[i].Kind[0] < resources[j].Kind[0]
-	})
+	// Everything started from here on runs until Disconnect
+	ctx, cancel := context.WithCancel(ctx)
+	informerFactory := informers.NewSharedInformerFactory(clientset, time.Hour)
+	informerFactory.Start(ctx.Done())
+	dynamicInformerFactory := dynamicinformer.NewDynamicSharedInformerFactory(dynamicClient, time.Hour)
+	dynamicInformerFactory.Start(ctx.Done())
+
+	metrics, metricsErr := newMetrics(ctx, rclient, resources, namespaces)
+	if metricsErr != nil {
+		klog.Infof("metrics disabled: %s", metricsErr.Error())
+	}
 
 	cluster := Cluster{
 		Client:                 rclient,
-		Config:                 config,
 		Clientset:              clientset,
 		RESTMapper:             mapper,
 		Scheme:                 scheme,
@@ -162,17 +176,47 @@ func NewCluster(ctx context.Context, clusterPrefs pubsub.Property[ClusterPrefere
 		ClusterPreferences:     clusterPrefs,
 		DynamicClient:          dynamicClient,
 		Metrics:                metrics,
-		Events:                 newEvents(ctx, clientset),
+		Events:                 newEvents(ctx, clientset, namespaces),
 		ctx:                    ctx,
-		Resources:              resources,
+		cancel:                 cancel,
+		credentials:            credentials,
+		Resources:              pubsub.NewProperty(resources),
+		Report:                 pubsub.NewProperty(ConnectionReport{}),
+		Connection:             pubsub.NewProperty(Connection{LastSync: time.Now()}),
+		watchErrors:            map[schema.GroupVersionResource]InformerFailure{},
+		mapper:                 mapper,
+		Namespaces:             namespaces,
+		rules:                  rules,
 		informerFactory:        informerFactory,
 		dynamicInformerFactory: dynamicInformerFactory,
 		sharedInformers:        map[schema.GroupVersionResource]informers.GenericInformer{},
+		informerHandlers:       map[schema.GroupVersionResource][]*informerHandler{},
+	}
+
+	cluster.informerCtx, cluster.informerCancel = context.WithCancel(ctx)
+	cluster.config.Store(config)
+	cluster.PortForwards = newPortForwards(&cluster)
+	cluster.setFailedGroups(failedGroups)
+	cluster.setDisabled("Metrics", metricsErr)
+	if len(namespaces) > 0 {
+		cluster.setDisabled("Cluster-wide access", fmt.Errorf("restricted to namespaces %s", strings.Join(namespaces, ", ")))
+	}
+	cluster.watchCredentials(ctx)
+	cluster.watchDiscovery(ctx)
+	go cluster.monitorConnection(ctx)
+	if cached {
+		go cluster.refreshDiscovery(ctx)
 	}
 
 	return &cluster, nil
 }
 
+// Disconnect stops monitoring the connection, the informers and everything
+// else that was started for the cluster.
+func (cluster *Cluster) Disconnect() {
+	cluster.cancel()
+}
+
 func (cluster *Cluster) GetReference(ctx context.Context, ref corev1.ObjectReference) (client.Object, error) {
 	var object client.Object
 	gvk := schema.FromAPIVersionAndKind(ref.APIVersion, ref.Kind).String()
@@ -195,7 +239,7 @@ func (cluster *Cluster) GetReference(ctx context.Context, ref corev1.ObjectRefer
 }
 
 func (cluster *Cluster) GetAPIResource(gvk schema.GroupVersionKind) *metav1.APIResource {
-	for _, res := range cluster.Resources {
+	for _, res := range cluster.Resources.Value() {
 		if util.GVKEquals(gvk, util.GVKForResource(&res)) {
 			return &res
 		}
@@ -213,12 +257,22 @@ func (cluster *Cluster) SetObjectGVK(object client.Object) error {
 }
 
 func (c *Cluster) GetInformer(gvr schema.GroupVersionResource) informers.GenericInformer {
+	// Informers are requested from the main loop and from goroutines
+	c.informerMutex.Lock()
+	defer c.informerMutex.Unlock()
+	return c.getInformer(gvr)
+}
+
+func (c *Cluster) getInformer(gvr schema.GroupVersionResource) informers.GenericInformer {
 	if informer, ok := c.sharedInformers[gvr]; ok {
 		return informer
 	}
 	var informer informers.GenericInformer
-	informer, err := c.informerFactory.ForResource(gvr)
-	if err != nil {
+	var err error
+	restricted := len(c.Namespaces) > 0 && c.namespaced(gvr)
+	if restricted {
+		informer = c.newNamespacedInformer(gvr)
+	} else if informer, err = c.informerFactory.ForResource(gvr); err != nil {
 		informer = c.dynamicInformerFactory.ForResource(gvr)
 	}
 	informer.Informer().SetWatchErrorHandler(func(r *cache.Reflector, err error) {
@@ -226,8 +280,12 @@ func (c *Cluster) GetInformer(gvr schema.GroupVersionResource) informers.Generic
 			return
 		}
 		klog.Errorf("%s informer: %s", gvr.Resource, err)
+		c.recordWatchError(gvr, r.LastSyncResourceVersion(), err)
 	})
 	informer.Informer().SetTransform(func(obj interface{}) (interface{}, error) {
+		if u, ok := obj.(*unstructured.Unstructured); ok && restricted {
+			obj = c.typed(u)
+		}
 		switch obj := obj.(type) {
 		case *unstructured.Unstructured:
 			return obj, nil
@@ -238,24 +296,112 @@ func (c *Cluster) GetInformer(gvr schema.GroupVersionResource) informers.Generic
 			return obj, nil
 		}
 	})
-	go informer.Informer().Run(c.ctx.Done())
+	// Watches that recovered deliver events again
+	synced := func() {
+		c.recordWatchSync(gvr, informer.Informer().LastSyncResourceVersion())
+	}
+	informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(_ interface{}) {
+			synced()
+		},
+		UpdateFunc: func(_, _ interface{}) {
+			synced()
+		},
+		DeleteFunc: func(_ interface{}) {
+			synced()
+		},
+	})
+	go informer.Informer().Run(c.informerCtx.Done())
 	c.sharedInformers[gvr] = informer
 	return informer
 }
 
+// AddInformerEventHandler adds the handler to the informer of the resource
+// until the context is done. The handler stays registered when informers are
+// restarted.
 func (c *Cluster) AddInformerEventHandler(ctx context.Context, gvr schema.GroupVersionResource, handler cache.ResourceEventHandler) error {
-	informer := c.GetInformer(gvr)
-	registration, err := informer.Informer().AddEventHandler(handler)
+	c.informerMutex.Lock()
+	defer c.informerMutex.Unlock()
+	registration, err := c.getInformer(gvr).Informer().AddEventHandler(handler)
 	if err != nil {
 		return err
 	}
+	h := &informerHandler{handler: handler, registration: registration}
+	c.informerHandlers[gvr] = append(c.informerHandlers[gvr], h)
 	go func() {
 		<-ctx.Done()
-		informer.Informer().RemoveEventHandler(registration)
+		c.informerMutex.Lock()
+		defer c.informerMutex.Unlock()
+		c.informerHandlers[gvr] = slices.DeleteFunc(c.informerHandlers[gvr], func(other *informerHandler) bool {
+			return other == h
+		})
+		if informer, ok := c.sharedInformers[gvr]; ok {
+			informer.Informer().RemoveEventHandler(h.registration)
+		}
 	}()
 	return nil
 }
 
+// restartInformers replaces all informers with new ones, so objects are listed
+// again right away instead of after the watch backoff. Handlers are moved to
+// the new informers and notified as if the informers had relisted.
+func (c *Cluster) restartInformers() {
+	c.informerMutex.Lock()
+	defer c.informerMutex.Unlock()
+
+	c.informerCancel()
+	c.informerCtx, c.informerCancel = context.WithCancel(c.ctx)
+	// Factories return the informers they already created, so they're replaced as well
+	c.informerFactory = informers.NewSharedInformerFactory(c.Clientset, time.Hour)
+	c.dynamicInformerFactory = dynamicinformer.NewDynamicSharedInformerFactory(c.DynamicClient, time.Hour)
+
+	previous := c.sharedInformers
+	c.sharedInformers = map[schema.GroupVersionResource]informers.GenericInformer{}
+	for gvr, old := range previous {
+		known := old.Informer().GetStore()
+		informer := c.getInformer(gvr)
+		handlers := slices.Clone(c.informerHandlers[gvr])
+		for _, h := range handlers {
+			registration, err := informer.Informer().AddEventHandler(&relistHandler{ResourceEventHandler: h.handler, known: known})
+			if err != nil {
+				klog.Infof("%s informer: %s", gvr.Resource, err)
+				continue
+			}
+			h.registration = registration
+		}
+		ctx := c.informerCtx
+		go func() {
+			if !cache.WaitForCacheSync(ctx.Done(), informer.Informer().HasSynced) {
+				return
+			}
+			for _, obj := range known.List() {
+				if _, exists, err := informer.Informer().GetStore().Get(obj); err == nil && !exists {
+					for _, h := range handlers {
+						h.handler.OnDelete(obj)
+					}
+				}
+			}
+		}()
+	}
+}
+
+// relistHandler passes the initial objects of a restarted informer as updates
+// of the objects the previous informer knew, like a relist would.
+type relistHandler struct {
+	cache.ResourceEventHandler
+	known cache.Store
+}
+
+func (h *relistHandler) OnAdd(obj interface{}, isInInitialList bool) {
+	if isInInitialList {
+		if old, exists, err := h.known.Get(obj); err == nil && exists {
+			h.ResourceEventHandler.OnUpdate(old, obj)
+			return
+		}
+	}
+	h.ResourceEventHandler.OnAdd(obj, isInInitialList)
+}
+
 func InformerConnectProperty[T client.Object](ctx context.Context, cluster *Cluster, gvr schema.GroupVersionResource, prop pubsub.Property[[]T]) error {
 	updateProperty, _ := debounce.Debounce(func() {
 		var objects []T
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/klog/v2"
+)
+
+const (
+	connectionProbeInterval = 10 * time.Second
+	connectionProbeTimeout  = 5 * time.Second
+)
+
+type ConnectionState int
+
+const (
+	ConnectionConnected ConnectionState = iota
+	// ConnectionDegraded means the API server responds, but isn't ready or
+	// watches keep failing.
+	ConnectionDegraded
+	// ConnectionOffline means the API server can't be reached, e.g. because the
+	// VPN dropped. Objects shown are stale.
+	ConnectionOffline
+	// ConnectionUnauthorized means the API server rejects the credentials, e.g.
+	// because the token expired. Credentials are refreshed when entering it.
+	ConnectionUnauthorized
+)
+
+func (s ConnectionState) String() string {
+	switch s {
+	case ConnectionDegraded:
+		return "Degraded"
+	case ConnectionOffline:
+		return "Offline"
+	case ConnectionUnauthorized:
+		return "Unauthorized"
+	default:
+		return "Connected"
+	}
+}
+
+type Connection struct {
+	State ConnectionState
+	Err   error
+	// LastSync is the time the API server last responded.
+	LastSync time.Time
+}
+
+// monitorConnection probes /readyz of the API server and publishes the
+// connection state. Metrics are paused while offline. Exec credentials are
+// refreshed when they're rejected or the connection comes back, and the
+// cluster is resynced once requests succeed again.
+func (cluster *Cluster) monitorConnection(ctx context.Context) {
+	ticker := time.NewTicker(connectionProbeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		cluster.checkWatchRecovery()
+		previous := cluster.Connection.Value()
+		conn := cluster.probeConnection(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if conn.State == ConnectionOffline {
+			conn.LastSync = previous.LastSync
+		}
+		if conn.State != previous.State {
+			klog.Infof("connection %s: %v", conn.State, conn.Err)
+		}
+		cluster.Connection.Pub(conn)
+
+		cluster.Metrics.Pause(conn.State == ConnectionOffline)
+		if conn.State == ConnectionUnauthorized && previous.State != ConnectionUnauthorized ||
+			previous.State == ConnectionOffline && conn.State != ConnectionOffline {
+			cluster.refreshExecCredentials(ctx)
+		}
+		if (previous.State == ConnectionOffline || previous.State == ConnectionUnauthorized) &&
+			conn.State != ConnectionOffline && conn.State != ConnectionUnauthorized {
+			cluster.resync(ctx)
+		}
+	}
+}
+
+func (cluster *Cluster) probeConnection(ctx context.Context) Connection {
+	ctx, cancel := context.WithTimeout(ctx, connectionProbeTimeout)
+	defer cancel()
+	err := cluster.Discovery().RESTClient().Get().AbsPath("/readyz").Do(ctx).Error()
+	if apierrors.IsForbidden(err) {
+		// Some clusters don't allow everyone to check readiness, but the version
+		// is public to all users
+		err = cluster.Discovery().RESTClient().Get().AbsPath("/version").Do(ctx).Error()
+	}
+	if apierrors.IsUnauthorized(err) || apierrors.IsForbidden(err) {
+		return Connection{State: ConnectionUnauthorized, Err: err, LastSync: time.Now()}
+	}
+	if err != nil {
+		if _, ok := err.(apierrors.APIStatus); ok {
+			return Connection{State: ConnectionDegraded, Err: err, LastSync: time.Now()}
+		}
+		return Connection{State: ConnectionOffline, Err: err}
+	}
+	var failing int
+	for _, failure := range cluster.Report.Value().Informers {
+		// Forbidden watches won't recover, e.g. in namespace-restricted mode
+		if !apierrors.IsForbidden(failure.Err) {
+			failing++
+		}
+	}
+	if failing > 0 {
+		return Connection{State: ConnectionDegraded, Err: fmt.Errorf("%d watches keep failing", failing), LastSync: time.Now()}
+	}
+	return Connection{State: ConnectionConnected, LastSync: time.Now()}
+}
+
+// resync catches up after the connection came back. Watch errors from while
+// offline are dropped, discovery is refreshed and informers are restarted
+// instead of waiting for their watch backoff to expire.
+func (cluster *Cluster) resync(ctx context.Context) {
+	cluster.reportMutex.Lock()
+	cluster.watchErrors = map[schema.GroupVersionResource]InformerFailure{}
+	cluster.reportMutex.Unlock()
+	cluster.updateReport(func(report *ConnectionReport) {
+		report.Informers = nil
+	})
+	cluster.refreshDiscovery(ctx)
+	cluster.restartInformers()
+}
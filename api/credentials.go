@@ -0,0 +1,163 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	clientauthenticationv1 "k8s.io/client-go/pkg/apis/clientauthentication/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog/v2"
+)
+
+const execPluginTimeout = time.Minute
+
+// credentials holds the current bearer token of the cluster preferences and
+// the token of the last exec plugin refresh. Clients are created once per
+// connection, so refreshed tokens are set on each request instead.
+type credentials struct {
+	token atomic.Pointer[string]
+	exec  atomic.Pointer[execToken]
+}
+
+type execToken struct {
+	token   string
+	expires time.Time
+}
+
+// bearerToken returns the token to send, or an empty string to keep the one
+// set by the client. Refreshed exec tokens are dropped once they expire, as
+// the exec plugin then runs again by itself.
+func (c *credentials) bearerToken() string {
+	if exec := c.exec.Load(); exec != nil && (exec.expires.IsZero() || time.Now().Before(exec.expires)) {
+		return exec.token
+	}
+	if token := c.token.Load(); token != nil {
+		return *token
+	}
+	return ""
+}
+
+type credentialsRoundTripper struct {
+	rt          http.RoundTripper
+	credentials *credentials
+}
+
+func (rt *credentialsRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if token := rt.credentials.bearerToken(); token != "" && req.Header.Get("Authorization") != "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return rt.rt.RoundTrip(req)
+}
+
+func (c *credentials) wrap(config *rest.Config) {
+	config.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return &credentialsRoundTripper{rt: rt, credentials: c}
+	})
+}
+
+// watchCredentials applies credentials refreshed in the cluster preferences,
+// e.g. after the kubeconfig file changed. Tokens apply to existing clients;
+// certificates and exec plugins apply to connections opened afterwards, such
+// as terminals and port forwards.
+func (cluster *Cluster) watchCredentials(ctx context.Context) {
+	cluster.ClusterPreferences.Sub(ctx, func(prefs ClusterPreferences) {
+		cluster.credentials.token.Store(&prefs.BearerToken)
+
+		config := cluster.Config()
+		if config.BearerToken == prefs.BearerToken && reflect.DeepEqual(config.TLSClientConfig, prefs.TLS) && reflect.DeepEqual(config.ExecProvider, prefs.Exec) {
+			return
+		}
+		config = rest.CopyConfig(config)
+		config.BearerToken = prefs.BearerToken
+		config.TLSClientConfig = prefs.TLS
+		config.ExecProvider = prefs.Exec
+		cluster.config.Store(config)
+		cluster.credentials.exec.Store(nil)
+	})
+}
+
+// refreshExecCredentials runs the exec plugin again. Its credentials are
+// cached by all clients until they expire, but may have been revoked while the
+// connection was down, e.g. when the VPN login expired. The refreshed token is
+// set on each request, client certificates can't be swapped.
+func (cluster *Cluster) refreshExecCredentials(ctx context.Context) {
+	config := cluster.Config()
+	if config.ExecProvider == nil {
+		return
+	}
+	cred, err := runExecPlugin(ctx, config)
+	if err != nil {
+		klog.Infof("refresh exec credentials: %v", err)
+		return
+	}
+	token := &execToken{token: cred.Status.Token}
+	if cred.Status.ExpirationTimestamp != nil {
+		token.expires = cred.Status.ExpirationTimestamp.Time
+	}
+	cluster.credentials.exec.Store(token)
+}
+
+// runExecPlugin runs the exec plugin of the config like client-go does, but
+// non-interactively and bypassing its credential cache.
+func runExecPlugin(ctx context.Context, config *rest.Config) (*clientauthenticationv1.ExecCredential, error) {
+	provider := config.ExecProvider
+	info := clientauthenticationv1.ExecCredential{}
+	info.APIVersion = provider.APIVersion
+	info.Kind = "ExecCredential"
+	if provider.ProvideClusterInfo {
+		info.Spec.Cluster = &clientauthenticationv1.Cluster{
+			Server:                   config.Host,
+			TLSServerName:            config.ServerName,
+			InsecureSkipTLSVerify:    config.Insecure,
+			CertificateAuthorityData: config.CAData,
+		}
+	}
+	data, err := json.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, execPluginTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, provider.Command, provider.Args...)
+	cmd.Env = os.Environ()
+	for _, env := range provider.Env {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", env.Name, env.Value))
+	}
+	cmd.Env = append(cmd.Env, fmt.Sprintf("KUBERNETES_EXEC_INFO=%s", data))
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+
+	var cred clientauthenticationv1.ExecCredential
+	if err := json.Unmarshal(out, &cred); err != nil {
+		return nil, fmt.Errorf("decoding exec credential: %w", err)
+	}
+	if cred.Status == nil || cred.Status.Token == "" {
+		return nil, errors.New("exec plugin returned no token")
+	}
+	return &cred, nil
+}
+
+// Config returns the current client config. It's replaced when credentials
+// change, so it should be read for each new connection.
+func (cluster *Cluster) Config() *rest.Config {
+	return cluster.config.Load()
+}
@@ -0,0 +1,312 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"path"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/zmwangx/debounce"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+// discoveryCacheTTL is how long cached discovery is used without waiting for
+// the API server, like kubectl's discovery cache.
+const discoveryCacheTTL = 6 * time.Hour
+
+// discoveryCache is the API discovery of a cluster host, stored on disk so
+// connecting doesn't wait for discovery of every API group.
+type discoveryCache struct {
+	Time   time.Time
+	Groups []*restmapper.APIGroupResources
+}
+
+func discoveryCachePath(host string) string {
+	cd, err := os.UserCacheDir()
+	if err != nil {
+		cd = os.TempDir()
+	}
+	if u, err := url.Parse(host); err == nil && u.Host != "" {
+		host = u.Host
+	}
+	host = strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '-' {
+			return r
+		}
+		return '_'
+	}, host)
+	return path.Join(cd, "seabird", "discovery", host+".json")
+}
+
+// loadDiscoveryCache returns the cached discovery of the host, if it's younger
+// than discoveryCacheTTL.
+func loadDiscoveryCache(host string) (*discoveryCache, bool) {
+	f, err := os.Open(discoveryCachePath(host))
+	if err != nil {
+		return nil, false
+	}
+	defer f.Close()
+	var c discoveryCache
+	if err := json.NewDecoder(f).Decode(&c); err != nil {
+		klog.Infof("discovery cache: %s", err)
+		return nil, false
+	}
+	if time.Since(c.Time) > discoveryCacheTTL || len(c.Groups) == 0 {
+		return nil, false
+	}
+	return &c, true
+}
+
+func (c *discoveryCache) save(host string) error {
+	p := discoveryCachePath(host)
+	if err := os.MkdirAll(path.Dir(p), os.ModePerm); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(path.Dir(p), ".discovery-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if err := json.NewEncoder(f).Encode(c); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), p)
+}
+
+// discover fetches the groups and resources of the API server. Groups that
+// fail discovery are left out and returned with their errors.
+func discover(client discovery.DiscoveryInterface) (*discoveryCache, map[schema.GroupVersion]error, error) {
+	groups, lists, err := client.ServerGroupsAndResources()
+	var failed map[schema.GroupVersion]error
+	if err != nil {
+		var groupDiscoveryFailed *discovery.ErrGroupDiscoveryFailed
+		if !errors.As(err, &groupDiscoveryFailed) || groups == nil {
+			return nil, nil, err
+		}
+		failed = groupDiscoveryFailed.Groups
+		for api, err := range failed {
+			klog.Infof("group discovery failed for '%s': %s", api.String(), err.Error())
+		}
+	}
+
+	byGroupVersion := map[string]*metav1.APIResourceList{}
+	for _, list := range lists {
+		byGroupVersion[list.GroupVersion] = list
+	}
+	c := &discoveryCache{Time: time.Now()}
+	for _, group := range groups {
+		res := &restmapper.APIGroupResources{
+			Group:              *group,
+			VersionedResources: map[string][]metav1.APIResource{},
+		}
+		for _, version := range group.Versions {
+			if list, ok := byGroupVersion[version.GroupVersion]; ok {
+				res.VersionedResources[version.Version] = list.APIResources
+			}
+		}
+		c.Groups = append(c.Groups, res)
+	}
+	return c, failed, nil
+}
+
+// preferredResources returns the resources of each group, in the preferred
+// version of the group or else the first version that serves them, like
+// ServerPreferredResources.
+func preferredResources(groups []*restmapper.APIGroupResources) []*metav1.APIResourceList {
+	var lists []*metav1.APIResourceList
+	for _, group := range groups {
+		versions := []string{group.Group.PreferredVersion.Version}
+		for _, version := range group.Group.Versions {
+			if !slices.Contains(versions, version.Version) {
+				versions = append(versions, version.Version)
+			}
+		}
+		seen := map[string]bool{}
+		for _, version := range versions {
+			list := &metav1.APIResourceList{
+				GroupVersion: schema.GroupVersion{Group: group.Group.Name, Version: version}.String(),
+			}
+			for _, res := range group.VersionedResources[version] {
+				if seen[res.Name] {
+					continue
+				}
+				seen[res.Name] = true
+				list.APIResources = append(list.APIResources, res)
+			}
+			if len(list.APIResources) > 0 {
+				lists = append(lists, list)
+			}
+		}
+	}
+	return lists
+}
+
+// apiResources returns the preferred resources of the groups that can be
+// listed, sorted by kind.
+func apiResources(groups []*restmapper.APIGroupResources) ([]metav1.APIResource, error) {
+	var resources []metav1.APIResource
+	for _, list := range preferredResources(groups) {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			return nil, err
+		}
+		for _, res := range list.APIResources {
+			if res.Group == "" {
+				res.Group = gv.Group
+			}
+			if res.Version == "" {
+				res.Version = gv.Version
+			}
+
+			if !slices.Contains(res.Verbs, "get") || !slices.Contains(res.Verbs, "list") {
+				continue
+			}
+
+			resources = append(resources, res)
+		}
+	}
+
+	sort.Slice(resources, func(i, j int) bool {
+		return resources[i].Kind[0] < resources[j].Kind[0]
+	})
+
+	return resources, nil
+}
+
+// refreshDiscovery re-runs discovery and updates the REST mapper, the
+// resources and, if all groups were discovered, the discovery cache of the
+// cluster host. Resources are only published when they changed, as that
+// rebuilds the navigation.
+func (cluster *Cluster) refreshDiscovery(ctx context.Context) {
+	c, failed, err := discover(cluster.Discovery())
+	if err != nil {
+		klog.Infof("discovery refresh failed: %s", err)
+		return
+	}
+	resources, err := apiResources(c.Groups)
+	if err != nil {
+		klog.Infof("discovery refresh failed: %s", err)
+		return
+	}
+	resources = filterListable(cluster.rules, resources)
+	if ctx.Err() != nil {
+		return
+	}
+	cluster.setFailedGroups(failed)
+	cluster.mapper.set(restmapper.NewDiscoveryRESTMapper(c.Groups))
+	if !reflect.DeepEqual(resources, cluster.Resources.Value()) {
+		cluster.Resources.Pub(resources)
+	}
+	// Partial discovery isn't cached, as connecting from the cache wouldn't
+	// report the failed groups
+	if len(failed) > 0 {
+		return
+	}
+	if err := c.save(cluster.Config().Host); err != nil {
+		klog.Infof("discovery cache: %s", err)
+	}
+}
+
+// watchDiscovery refreshes discovery when CRDs or API services change, so
+// resources installed while connected can be opened right away.
+func (cluster *Cluster) watchDiscovery(ctx context.Context) {
+	refresh, _ := debounce.Debounce(func() {
+		cluster.refreshDiscovery(ctx)
+	}, time.Second)
+
+	handler := cache.ResourceEventHandlerDetailedFuncs{
+		AddFunc: func(_ interface{}, isInInitialList bool) {
+			if !isInInitialList {
+				refresh()
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			o, ok1 := oldObj.(metav1.Object)
+			n, ok2 := newObj.(metav1.Object)
+			if !ok1 || !ok2 || o.GetResourceVersion() != n.GetResourceVersion() {
+				refresh()
+			}
+		},
+		DeleteFunc: func(_ interface{}) {
+			refresh()
+		},
+	}
+	for _, gvr := range []schema.GroupVersionResource{
+		apiextensionsv1.SchemeGroupVersion.WithResource("customresourcedefinitions"),
+		{Group: "apiregistration.k8s.io", Version: "v1", Resource: "apiservices"},
+	} {
+		if !cluster.canList(gvr) {
+			continue
+		}
+		if err := cluster.AddInformerEventHandler(ctx, gvr, handler); err != nil {
+			klog.Infof("watch %s: %s", gvr.Resource, err)
+		}
+	}
+}
+
+// reloadableRESTMapper delegates to a REST mapper that is replaced when
+// discovery is revalidated.
+type reloadableRESTMapper struct {
+	mapper atomic.Pointer[meta.RESTMapper]
+}
+
+func newReloadableRESTMapper(mapper meta.RESTMapper) *reloadableRESTMapper {
+	m := &reloadableRESTMapper{}
+	m.set(mapper)
+	return m
+}
+
+func (m *reloadableRESTMapper) set(mapper meta.RESTMapper) {
+	m.mapper.Store(&mapper)
+}
+
+func (m *reloadableRESTMapper) get() meta.RESTMapper {
+	return *m.mapper.Load()
+}
+
+func (m *reloadableRESTMapper) KindFor(resource schema.GroupVersionResource) (schema.GroupVersionKind, error) {
+	return m.get().KindFor(resource)
+}
+
+func (m *reloadableRESTMapper) KindsFor(resource schema.GroupVersionResource) ([]schema.GroupVersionKind, error) {
+	return m.get().KindsFor(resource)
+}
+
+func (m *reloadableRESTMapper) ResourceFor(input schema.GroupVersionResource) (schema.GroupVersionResource, error) {
+	return m.get().ResourceFor(input)
+}
+
+func (m *reloadableRESTMapper) ResourcesFor(input schema.GroupVersionResource) ([]schema.GroupVersionResource, error) {
+	return m.get().ResourcesFor(input)
+}
+
+func (m *reloadableRESTMapper) RESTMapping(gk schema.GroupKind, versions ...string) (*meta.RESTMapping, error) {
+	return m.get().RESTMapping(gk, versions...)
+}
+
+func (m *reloadableRESTMapper) RESTMappings(gk schema.GroupKind, versions ...string) ([]*meta.RESTMapping, error) {
+	return m.get().RESTMappings(gk, versions...)
+}
+
+func (m *reloadableRESTMapper) ResourceSingularizer(resource string) (string, error) {
+	return m.get().ResourceSingularizer(resource)
+}
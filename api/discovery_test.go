@@ -0,0 +1,186 @@
+package api
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/restmapper"
+)
+
+type stubDiscovery struct {
+	discovery.DiscoveryInterface
+	groups    []*metav1.APIGroup
+	resources []*metav1.APIResourceList
+	err       error
+}
+
+func (d *stubDiscovery) ServerGroupsAndResources() ([]*metav1.APIGroup, []*metav1.APIResourceList, error) {
+	return d.groups, d.resources, d.err
+}
+
+func TestDiscover(t *testing.T) {
+	core := &metav1.APIGroup{
+		Versions:         []metav1.GroupVersionForDiscovery{{GroupVersion: "v1", Version: "v1"}},
+		PreferredVersion: metav1.GroupVersionForDiscovery{GroupVersion: "v1", Version: "v1"},
+	}
+	metrics := &metav1.APIGroup{
+		Name:             "metrics.k8s.io",
+		Versions:         []metav1.GroupVersionForDiscovery{{GroupVersion: "metrics.k8s.io/v1beta1", Version: "v1beta1"}},
+		PreferredVersion: metav1.GroupVersionForDiscovery{GroupVersion: "metrics.k8s.io/v1beta1", Version: "v1beta1"},
+	}
+	pods := metav1.APIResource{Name: "pods", Kind: "Pod", Namespaced: true}
+	metricsGV := schema.GroupVersion{Group: "metrics.k8s.io", Version: "v1beta1"}
+	unavailable := errors.New("service unavailable")
+
+	tests := []struct {
+		name       string
+		client     *stubDiscovery
+		want       []*restmapper.APIGroupResources
+		wantFailed map[schema.GroupVersion]error
+		wantErr    bool
+	}{
+		{
+			name: "all groups",
+			client: &stubDiscovery{
+				groups:    []*metav1.APIGroup{core},
+				resources: []*metav1.APIResourceList{{GroupVersion: "v1", APIResources: []metav1.APIResource{pods}}},
+			},
+			want: []*restmapper.APIGroupResources{
+				{Group: *core, VersionedResources: map[string][]metav1.APIResource{"v1": {pods}}},
+			},
+		},
+		{
+			name: "failed group",
+			client: &stubDiscovery{
+				groups:    []*metav1.APIGroup{core, metrics},
+				resources: []*metav1.APIResourceList{{GroupVersion: "v1", APIResources: []metav1.APIResource{pods}}},
+				err:       &discovery.ErrGroupDiscoveryFailed{Groups: map[schema.GroupVersion]error{metricsGV: unavailable}},
+			},
+			want: []*restmapper.APIGroupResources{
+				{Group: *core, VersionedResources: map[string][]metav1.APIResource{"v1": {pods}}},
+				{Group: *metrics, VersionedResources: map[string][]metav1.APIResource{}},
+			},
+			wantFailed: map[schema.GroupVersion]error{metricsGV: unavailable},
+		},
+		{
+			name:    "failed",
+			client:  &stubDiscovery{err: unavailable},
+			wantErr: true,
+		},
+		{
+			name:    "failed groups without groups",
+			client:  &stubDiscovery{err: &discovery.ErrGroupDiscoveryFailed{Groups: map[schema.GroupVersion]error{metricsGV: unavailable}}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, failed, err := discover(tt.client)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("discover() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(got.Groups, tt.want) {
+				t.Errorf("discover() groups = %v, want %v", got.Groups, tt.want)
+			}
+			if !reflect.DeepEqual(failed, tt.wantFailed) {
+				t.Errorf("discover() failed = %v, want %v", failed, tt.wantFailed)
+			}
+		})
+	}
+}
+
+func TestPreferredResources(t *testing.T) {
+	widgets := metav1.APIResource{Name: "widgets", Kind: "Widget"}
+	gadgets := metav1.APIResource{Name: "gadgets", Kind: "Gadget"}
+	tests := []struct {
+		name   string
+		groups []*restmapper.APIGroupResources
+		want   []*metav1.APIResourceList
+	}{
+		{
+			name: "preferred version first",
+			groups: []*restmapper.APIGroupResources{{
+				Group: metav1.APIGroup{
+					Name:             "example.com",
+					Versions:         []metav1.GroupVersionForDiscovery{{Version: "v1beta1"}, {Version: "v1"}},
+					PreferredVersion: metav1.GroupVersionForDiscovery{Version: "v1"},
+				},
+				VersionedResources: map[string][]metav1.APIResource{
+					"v1":      {widgets},
+					"v1beta1": {widgets, gadgets},
+				},
+			}},
+			want: []*metav1.APIResourceList{
+				{GroupVersion: "example.com/v1", APIResources: []metav1.APIResource{widgets}},
+				{GroupVersion: "example.com/v1beta1", APIResources: []metav1.APIResource{gadgets}},
+			},
+		},
+		{
+			name: "versions without resources",
+			groups: []*restmapper.APIGroupResources{{
+				Group: metav1.APIGroup{
+					Versions:         []metav1.GroupVersionForDiscovery{{Version: "v1"}, {Version: "v2"}},
+					PreferredVersion: metav1.GroupVersionForDiscovery{Version: "v1"},
+				},
+				VersionedResources: map[string][]metav1.APIResource{"v2": {widgets}},
+			}},
+			want: []*metav1.APIResourceList{
+				{GroupVersion: "v2", APIResources: []metav1.APIResource{widgets}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := preferredResources(tt.groups); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("preferredResources() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAPIResources(t *testing.T) {
+	groups := []*restmapper.APIGroupResources{
+		{
+			Group: metav1.APIGroup{
+				Versions:         []metav1.GroupVersionForDiscovery{{Version: "v1"}},
+				PreferredVersion: metav1.GroupVersionForDiscovery{Version: "v1"},
+			},
+			VersionedResources: map[string][]metav1.APIResource{"v1": {
+				{Name: "pods", Kind: "Pod", Verbs: []string{"get", "list", "watch"}},
+				{Name: "bindings", Kind: "Binding", Verbs: []string{"create"}},
+				{Name: "configmaps", Kind: "ConfigMap", Verbs: []string{"get", "list"}},
+			}},
+		},
+		{
+			Group: metav1.APIGroup{
+				Name:             "apps",
+				Versions:         []metav1.GroupVersionForDiscovery{{Version: "v1"}},
+				PreferredVersion: metav1.GroupVersionForDiscovery{Version: "v1"},
+			},
+			VersionedResources: map[string][]metav1.APIResource{"v1": {
+				{Name: "deployments", Kind: "Deployment", Verbs: []string{"get", "list"}},
+				{Name: "deployments/scale", Kind: "Scale", Group: "autoscaling", Version: "v1", Verbs: []string{"get"}},
+			}},
+		},
+	}
+	want := []metav1.APIResource{
+		{Name: "configmaps", Kind: "ConfigMap", Version: "v1", Verbs: []string{"get", "list"}},
+		{Name: "deployments", Kind: "Deployment", Group: "apps", Version: "v1", Verbs: []string{"get", "list"}},
+		{Name: "pods", Kind: "Pod", Version: "v1", Verbs: []string{"get", "list", "watch"}},
+	}
+
+	got, err := apiResources(groups)
+	if err != nil {
+		t.Fatalf("apiResources() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("apiResources() = %v, want %v", got, want)
+	}
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	"github.com/getseabird/seabird/internal/pubsub"
@@ -17,51 +18,65 @@ type Events struct {
 	events pubsub.Property[[]*eventsv1.Event]
 }
 
-func newEvents(ctx context.Context, clientset *kubernetes.Clientset) *Events {
+// newEvents watches events in the namespaces, or in all namespaces if none
+// are given.
+func newEvents(ctx context.Context, clientset *kubernetes.Clientset, namespaces []string) *Events {
 	e := Events{
 		events: pubsub.NewProperty([]*eventsv1.Event{}),
 	}
+	if len(namespaces) == 0 {
+		namespaces = []string{v1.NamespaceAll}
+	}
+	var mutex sync.Mutex
 	var events []*eventsv1.Event
-	watchlist := cache.NewListWatchFromClient(clientset.EventsV1().RESTClient(), "events", v1.NamespaceAll,
-		fields.Everything())
-	_, controller := cache.NewInformer(watchlist, &eventsv1.Event{}, time.Minute*10,
-		cache.ResourceEventHandlerFuncs{
-			AddFunc: func(o interface{}) {
-				switch obj := o.(type) {
-				case *eventsv1.Event:
-					events = append(events, obj)
-					e.events.Pub(events)
-				}
-			},
-			DeleteFunc: func(o interface{}) {
-				switch obj := o.(type) {
-				case *eventsv1.Event:
-					for i, o := range events {
-						if o.GetUID() == obj.GetUID() {
-							events = append(events[:i], events[i+1:]...)
-							e.events.Pub(events)
-							break
+	for _, namespace := range namespaces {
+		watchlist := cache.NewListWatchFromClient(clientset.EventsV1().RESTClient(), "events", namespace,
+			fields.Everything())
+		_, controller := cache.NewInformer(watchlist, &eventsv1.Event{}, time.Minute*10,
+			cache.ResourceEventHandlerFuncs{
+				AddFunc: func(o interface{}) {
+					mutex.Lock()
+					defer mutex.Unlock()
+					switch obj := o.(type) {
+					case *eventsv1.Event:
+						events = append(events, obj)
+						e.events.Pub(events)
+					}
+				},
+				DeleteFunc: func(o interface{}) {
+					mutex.Lock()
+					defer mutex.Unlock()
+					switch obj := o.(type) {
+					case *eventsv1.Event:
+						for i, o := range events {
+							if o.GetUID() == obj.GetUID() {
+								events = append(events[:i], events[i+1:]...)
+								e.events.Pub(events)
+								break
+							}
 						}
 					}
-				}
 
-			},
-			UpdateFunc: func(oldObj, newObj interface{}) {
-				switch obj := newObj.(type) {
-				case *eventsv1.Event:
-					for i, o := range events {
-						if o.GetUID() == obj.GetUID() {
-							events[i] = obj
-							e.events.Pub(events)
-							break
+				},
+				UpdateFunc: func(oldObj, newObj interface{}) {
+					mutex.Lock()
+					defer mutex.Unlock()
+					switch obj := newObj.(type) {
+					case *eventsv1.Event:
+						for i, o := range events {
+							if o.GetUID() == obj.GetUID() {
+								events[i] = obj
+								e.events.Pub(events)
+								break
+							}
 						}
 					}
-				}
+				},
 			},
-		},
-	)
+		)
 
-	go controller.Run(ctx.Done())
+		go controller.Run(ctx.Done())
+	}
 
 	return &e
 }
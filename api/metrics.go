@@ -3,6 +3,7 @@ package api
 import (
 	"context"
 	"errors"
+	"sync/atomic"
 	"time"
 
 	"github.com/getseabird/seabird/internal/pubsub"
@@ -17,16 +18,19 @@ import (
 type Metrics struct {
 	podMetrics  pubsub.Property[[]metricsv1beta1.PodMetrics]
 	nodeMetrics pubsub.Property[[]metricsv1beta1.NodeMetrics]
+	paused      atomic.Bool
 }
 
-func newMetrics(ctx context.Context, client client.Client, resources []metav1.APIResource) (*Metrics, error) {
-	m := Metrics{
+// newMetrics polls the metrics API every minute. With namespaces, pod metrics
+// are listed in those namespaces only and node metrics aren't listed.
+func newMetrics(ctx context.Context, c client.Client, resources []metav1.APIResource, namespaces []string) (*Metrics, error) {
+	m := &Metrics{
 		podMetrics:  pubsub.NewProperty([]metricsv1beta1.PodMetrics{}),
 		nodeMetrics: pubsub.NewProperty([]metricsv1beta1.NodeMetrics{}),
 	}
 
 	if !metricsAPIAvailable(resources) {
-		return &m, errors.New("no compatible metrics API detected")
+		return m, errors.New("no compatible metrics API detected")
 	}
 
 	go func() {
@@ -35,24 +39,46 @@ func newMetrics(ctx context.Context, client client.Client, resources []metav1.AP
 			case <-ctx.Done():
 				return
 			default:
-				var podMetricsList metricsv1beta1.PodMetricsList
-				if err := client.List(ctx, &podMetricsList); err != nil {
-					klog.Infof("unable to fetch pod metrics: %s", err.Error())
+				if m.paused.Load() {
+					time.Sleep(time.Second)
+					continue
 				}
-				m.podMetrics.Pub(podMetricsList.Items)
+				if len(namespaces) == 0 {
+					var podMetricsList metricsv1beta1.PodMetricsList
+					if err := c.List(ctx, &podMetricsList); err != nil {
+						klog.Infof("unable to fetch pod metrics: %s", err.Error())
+					}
+					m.podMetrics.Pub(podMetricsList.Items)
 
-				var nodeMetricsList metricsv1beta1.NodeMetricsList
-				if err := client.List(ctx, &nodeMetricsList); err != nil {
-					klog.Infof("unable to fetch node metrics: %s", err.Error())
+					var nodeMetricsList metricsv1beta1.NodeMetricsList
+					if err := c.List(ctx, &nodeMetricsList); err != nil {
+						klog.Infof("unable to fetch node metrics: %s", err.Error())
+					}
+					m.nodeMetrics.Pub(nodeMetricsList.Items)
+				} else {
+					var podMetrics []metricsv1beta1.PodMetrics
+					for _, namespace := range namespaces {
+						var podMetricsList metricsv1beta1.PodMetricsList
+						if err := c.List(ctx, &podMetricsList, client.InNamespace(namespace)); err != nil {
+							klog.Infof("unable to fetch pod metrics in %s: %s", namespace, err.Error())
+						}
+						podMetrics = append(podMetrics, podMetricsList.Items...)
+					}
+					m.podMetrics.Pub(podMetrics)
 				}
-				m.nodeMetrics.Pub(nodeMetricsList.Items)
 
 				time.Sleep(1 * time.Minute)
 			}
 		}
 	}()
 
-	return &m, nil
+	return m, nil
+}
+
+// Pause stops polling metrics, e.g. while the cluster is offline. The last
+// metrics are kept. Polling resumes right away when unpaused.
+func (m *Metrics) Pause(paused bool) {
+	m.paused.Store(paused)
 }
 
 func (m *Metrics) Pod(name types.NamespacedName) *metricsv1beta1.PodMetrics {
@@ -0,0 +1,279 @@
+package api
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+// detectNamespaces returns the namespaces informers are restricted to, or nil
+// for cluster-wide informers. Unless configured, users that can't list pods
+// cluster-wide are restricted to the namespace of their kubeconfig context.
+func detectNamespaces(ctx context.Context, clientset *kubernetes.Clientset, prefs ClusterPreferences) []string {
+	if len(prefs.Namespaces) > 0 {
+		return prefs.Namespaces
+	}
+	review, err := clientset.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, &authorizationv1.SelfSubjectAccessReview{
+		Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{Verb: "list", Resource: "pods"},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		klog.Infof("access review: %s", err)
+		return nil
+	}
+	if review.Status.Allowed {
+		return nil
+	}
+	if prefs.Kubeconfig != nil && prefs.Kubeconfig.Namespace != "" {
+		return []string{prefs.Kubeconfig.Namespace}
+	}
+	return []string{metav1.NamespaceDefault}
+}
+
+// listRules returns the rules of the user in the namespaces. It returns nil if
+// the rules are incomplete, e.g. because a webhook authorizer is used.
+func listRules(ctx context.Context, clientset *kubernetes.Clientset, namespaces []string) []authorizationv1.ResourceRule {
+	var rules []authorizationv1.ResourceRule
+	for _, namespace := range namespaces {
+		review, err := clientset.AuthorizationV1().SelfSubjectRulesReviews().Create(ctx, &authorizationv1.SelfSubjectRulesReview{
+			Spec: authorizationv1.SelfSubjectRulesReviewSpec{Namespace: namespace},
+		}, metav1.CreateOptions{})
+		if err != nil {
+			klog.Infof("rules review: %s", err)
+			return nil
+		}
+		if review.Status.Incomplete {
+			return nil
+		}
+		rules = append(rules, review.Status.ResourceRules...)
+	}
+	return rules
+}
+
+// canList reports whether the rules allow listing the resource. Without rules,
+// everything is assumed to be listable.
+func canList(rules []authorizationv1.ResourceRule, group, resource string) bool {
+	if rules == nil {
+		return true
+	}
+	matches := func(values []string, value string) bool {
+		return slices.Contains(values, value) || slices.Contains(values, "*")
+	}
+	for _, rule := range rules {
+		if len(rule.ResourceNames) > 0 {
+			continue
+		}
+		if matches(rule.Verbs, "list") && matches(rule.APIGroups, group) && matches(rule.Resources, resource) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterListable drops the resources the rules don't allow listing.
+func filterListable(rules []authorizationv1.ResourceRule, resources []metav1.APIResource) []metav1.APIResource {
+	return slices.DeleteFunc(resources, func(res metav1.APIResource) bool {
+		return !canList(rules, res.Group, res.Name)
+	})
+}
+
+func (cluster *Cluster) canList(gvr schema.GroupVersionResource) bool {
+	return canList(cluster.rules, gvr.Group, gvr.Resource)
+}
+
+// namespaced reports whether the resource is namespaced, according to the
+// REST mapper.
+func (cluster *Cluster) namespaced(gvr schema.GroupVersionResource) bool {
+	gvk, err := cluster.RESTMapper.KindFor(gvr)
+	if err != nil {
+		return false
+	}
+	mapping, err := cluster.RESTMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return false
+	}
+	return mapping.Scope.Name() == meta.RESTScopeNameNamespace
+}
+
+// newNamespacedInformer returns an informer of the resource in the allowed
+// namespaces. It uses the dynamic client, objects are converted to typed
+// objects by the transform of GetInformer.
+func (cluster *Cluster) newNamespacedInformer(gvr schema.GroupVersionResource) informers.GenericInformer {
+	lw := &namespacedListWatch{
+		ctx:        cluster.ctx,
+		resource:   cluster.DynamicClient.Resource(gvr),
+		namespaces: cluster.Namespaces,
+	}
+	informer := cache.NewSharedIndexInformer(lw, &unstructured.Unstructured{}, time.Hour, cache.Indexers{
+		cache.NamespaceIndex: cache.MetaNamespaceIndexFunc,
+	})
+	return &genericInformer{informer: informer, resource: gvr.GroupResource()}
+}
+
+// typed converts objects of types known to the scheme, so restricted informers
+// return the same objects as cluster-wide informers.
+func (cluster *Cluster) typed(obj *unstructured.Unstructured) runtime.Object {
+	typed, err := cluster.Scheme.New(obj.GroupVersionKind())
+	if err != nil {
+		return obj
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, typed); err != nil {
+		klog.Infof("convert %s: %s", obj.GroupVersionKind(), err)
+		return obj
+	}
+	return typed
+}
+
+type genericInformer struct {
+	informer cache.SharedIndexInformer
+	resource schema.GroupResource
+}
+
+func (i *genericInformer) Informer() cache.SharedIndexInformer {
+	return i.informer
+}
+
+func (i *genericInformer) Lister() cache.GenericLister {
+	return cache.NewGenericLister(i.informer.GetIndexer(), i.resource)
+}
+
+// namespacedListWatch lists and watches a resource in several namespaces, so
+// one informer covers all namespaces the user can access. Lists are merged
+// with the oldest resource version, so watches may replay a few events, which
+// informers handle as updates. Watches can't be resumed, as the last resource
+// version may be of another namespace, so informers list again instead.
+type namespacedListWatch struct {
+	ctx        context.Context
+	resource   dynamic.NamespaceableResourceInterface
+	namespaces []string
+}
+
+func (lw *namespacedListWatch) List(options metav1.ListOptions) (runtime.Object, error) {
+	// Continue tokens are per namespace, so lists aren't paginated
+	options.Limit = 0
+	options.Continue = ""
+
+	merged := &unstructured.UnstructuredList{}
+	var resourceVersion uint64
+	for _, namespace := range lw.namespaces {
+		list, err := lw.resource.Namespace(namespace).List(lw.ctx, options)
+		if err != nil {
+			return nil, err
+		}
+		if merged.Object == nil {
+			merged.Object = list.Object
+		}
+		merged.Items = append(merged.Items, list.Items...)
+		if rv, err := strconv.ParseUint(list.GetResourceVersion(), 10, 64); err == nil && (resourceVersion == 0 || rv < resourceVersion) {
+			resourceVersion = rv
+		}
+	}
+	if resourceVersion > 0 {
+		merged.SetResourceVersion(strconv.FormatUint(resourceVersion, 10))
+	}
+	merged.SetContinue("")
+	return merged, nil
+}
+
+func (lw *namespacedListWatch) Watch(options metav1.ListOptions) (watch.Interface, error) {
+	// Bookmarks of one namespace would skip events of the others
+	options.AllowWatchBookmarks = false
+
+	var watches []watch.Interface
+	for _, namespace := range lw.namespaces {
+		w, err := lw.resource.Namespace(namespace).Watch(lw.ctx, options)
+		if err != nil {
+			for _, w := range watches {
+				w.Stop()
+			}
+			return nil, err
+		}
+		watches = append(watches, w)
+	}
+	return newMergedWatch(watches), nil
+}
+
+// mergedWatch forwards the events of several watches. When any of the watches
+// ends, it sends an expired error and ends, so the informer lists again
+// instead of resuming all watches from a resource version of one namespace.
+type mergedWatch struct {
+	watches []watch.Interface
+	result  chan watch.Event
+	stop    chan struct{}
+	once    sync.Once
+}
+
+func newMergedWatch(watches []watch.Interface) *mergedWatch {
+	m := &mergedWatch{
+		watches: watches,
+		result:  make(chan watch.Event),
+		stop:    make(chan struct{}),
+	}
+	var wg sync.WaitGroup
+	for _, w := range watches {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer m.Stop()
+			for {
+				select {
+				case <-m.stop:
+					return
+				case ev, ok := <-w.ResultChan():
+					if !ok {
+						select {
+						case <-m.stop:
+							// The merged watch was stopped, not this one
+							return
+						default:
+						}
+						ev = watch.Event{Type: watch.Error, Object: &apierrors.NewResourceExpired("namespace watch ended").ErrStatus}
+					}
+					select {
+					case m.result <- ev:
+					case <-m.stop:
+						return
+					}
+					if !ok {
+						return
+					}
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(m.result)
+	}()
+	return m
+}
+
+func (m *mergedWatch) Stop() {
+	m.once.Do(func() {
+		close(m.stop)
+		for _, w := range m.watches {
+			w.Stop()
+		}
+	})
+}
+
+func (m *mergedWatch) ResultChan() <-chan watch.Event {
+	return m.result
+}
@@ -0,0 +1,158 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+func TestCanList(t *testing.T) {
+	rules := []authorizationv1.ResourceRule{
+		{Verbs: []string{"get", "list", "watch"}, APIGroups: []string{""}, Resources: []string{"pods", "services"}},
+		{Verbs: []string{"list"}, APIGroups: []string{"apps"}, Resources: []string{"*"}},
+		{Verbs: []string{"*"}, APIGroups: []string{"*"}, Resources: []string{"configmaps"}},
+		{Verbs: []string{"list"}, APIGroups: []string{""}, Resources: []string{"secrets"}, ResourceNames: []string{"token"}},
+		{Verbs: []string{"get"}, APIGroups: []string{"batch"}, Resources: []string{"jobs"}},
+	}
+	tests := []struct {
+		name     string
+		rules    []authorizationv1.ResourceRule
+		group    string
+		resource string
+		want     bool
+	}{
+		{name: "without rules", rules: nil, group: "", resource: "nodes", want: true},
+		{name: "no matching rule", rules: []authorizationv1.ResourceRule{}, group: "", resource: "pods", want: false},
+		{name: "listed resource", rules: rules, group: "", resource: "services", want: true},
+		{name: "other group", rules: rules, group: "batch", resource: "pods", want: false},
+		{name: "wildcard resource", rules: rules, group: "apps", resource: "deployments", want: true},
+		{name: "wildcard verb and group", rules: rules, group: "example.com", resource: "configmaps", want: true},
+		{name: "resource names", rules: rules, group: "", resource: "secrets", want: false},
+		{name: "other verb", rules: rules, group: "batch", resource: "jobs", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := canList(tt.rules, tt.group, tt.resource); got != tt.want {
+				t.Errorf("canList(%q, %q) = %v, want %v", tt.group, tt.resource, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterListable(t *testing.T) {
+	pods := metav1.APIResource{Name: "pods", Kind: "Pod"}
+	nodes := metav1.APIResource{Name: "nodes", Kind: "Node"}
+	deployments := metav1.APIResource{Name: "deployments", Group: "apps", Kind: "Deployment"}
+	tests := []struct {
+		name  string
+		rules []authorizationv1.ResourceRule
+		want  []metav1.APIResource
+	}{
+		{
+			name: "without rules",
+			want: []metav1.APIResource{pods, nodes, deployments},
+		},
+		{
+			name: "restricted",
+			rules: []authorizationv1.ResourceRule{
+				{Verbs: []string{"list"}, APIGroups: []string{""}, Resources: []string{"pods"}},
+				{Verbs: []string{"list"}, APIGroups: []string{"apps"}, Resources: []string{"deployments"}},
+			},
+			want: []metav1.APIResource{pods, deployments},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resources := []metav1.APIResource{pods, nodes, deployments}
+			if got := filterListable(tt.rules, resources); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("filterListable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergedWatch(t *testing.T) {
+	event := func(name string) watch.Event {
+		return watch.Event{Type: watch.Added, Object: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name}}}
+	}
+	tests := []struct {
+		name string
+		// events are sent on the watches in order, by index of the watch
+		events []int
+		// stop ends the watch with this index after the events
+		stop int
+	}{
+		{name: "single watch", events: []int{0, 0}, stop: 0},
+		{name: "several watches", events: []int{0, 1, 2, 1}, stop: 2},
+		{name: "no events", stop: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			watches := []*watch.FakeWatcher{watch.NewFake(), watch.NewFake(), watch.NewFake()}
+			var interfaces []watch.Interface
+			for _, w := range watches {
+				interfaces = append(interfaces, w)
+			}
+			m := newMergedWatch(interfaces)
+
+			for i, index := range tt.events {
+				name := string(rune('a' + i))
+				watches[index].Add(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name}})
+				select {
+				case got := <-m.ResultChan():
+					if !reflect.DeepEqual(got, event(name)) {
+						t.Fatalf("event = %v, want %v", got, event(name))
+					}
+				case <-time.After(time.Second):
+					t.Fatalf("event %s wasn't forwarded", name)
+				}
+			}
+
+			// Ending one watch makes the informer list again, as resuming from the
+			// last resource version would skip events of the other namespaces
+			watches[tt.stop].Stop()
+			select {
+			case got, ok := <-m.ResultChan():
+				if !ok || got.Type != watch.Error || !apierrors.IsResourceExpired(apierrors.FromObject(got.Object)) {
+					t.Fatalf("event = %v, want expired error", got)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("watch end wasn't reported")
+			}
+
+			// It ends the merged watch and stops the others
+			select {
+			case _, ok := <-m.ResultChan():
+				if ok {
+					t.Fatal("unexpected event after stop")
+				}
+			case <-time.After(time.Second):
+				t.Fatal("result channel wasn't closed")
+			}
+			for i, w := range watches {
+				if !w.IsStopped() {
+					t.Errorf("watch %d wasn't stopped", i)
+				}
+			}
+		})
+	}
+
+	t.Run("stop", func(t *testing.T) {
+		w := watch.NewFake()
+		m := newMergedWatch([]watch.Interface{w})
+		m.Stop()
+		m.Stop()
+		if _, ok := <-m.ResultChan(); ok {
+			t.Fatal("unexpected event after stop")
+		}
+		if !w.IsStopped() {
+			t.Error("watch wasn't stopped")
+		}
+	})
+}
@@ -0,0 +1,721 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/getseabird/seabird/internal/pubsub"
+	"github.com/google/uuid"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/transport/spdy"
+	"k8s.io/klog/v2"
+)
+
+const (
+	portForwardTimeout = 5 * time.Second
+	portForwardRetry   = 3 * time.Second
+
+	portForwardProbeInterval    = 15 * time.Second
+	portForwardProbeMaxInterval = 2 * time.Minute
+	portForwardProbeTimeout     = 3 * time.Second
+	portForwardLogInterval      = time.Minute
+)
+
+// Labels that differ between pods of the same workload and are dropped when
+// deriving a selector from a pod.
+var podHashLabels = []string{
+	"pod-template-hash",
+	"controller-revision-hash",
+	"statefulset.kubernetes.io/pod-name",
+	"apps.kubernetes.io/pod-index",
+}
+
+// PortForwardSpec describes what to forward. If the pod goes away, a ready pod
+// matching Selector, or a ready endpoint of the service, is used instead.
+type PortForwardSpec struct {
+	Namespace string
+	// Target is in kubectl notation, e.g. pod/web-0, service/web or
+	// deployment/web
+	Target string
+	// Pod is the pod currently forwarded to
+	Pod      string
+	Selector map[string]string
+	// Ports are in kubectl notation, [LOCAL]:REMOTE. For services, REMOTE is a
+	// service port number or name.
+	Ports []string
+	// Address is a comma-separated list of addresses to listen on, localhost
+	// if empty
+	Address string
+	// Paths are HTTP paths by remote port, used for probes and opening the
+	// port in the browser
+	Paths map[string]string
+}
+
+// Addresses returns the addresses to listen on.
+func (s PortForwardSpec) Addresses() []string {
+	var addresses []string
+	for _, address := range strings.Split(s.Address, ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			addresses = append(addresses, address)
+		}
+	}
+	if len(addresses) == 0 {
+		return []string{"localhost"}
+	}
+	return addresses
+}
+
+// Kind returns the kind of the target, e.g. pod or service.
+func (s PortForwardSpec) Kind() string {
+	kind, _, _ := strings.Cut(s.Target, "/")
+	return kind
+}
+
+// Name returns the name of the target.
+func (s PortForwardSpec) Name() string {
+	_, name, _ := strings.Cut(s.Target, "/")
+	return name
+}
+
+// PortForwardProfile is a named port forward saved in the cluster preferences.
+type PortForwardProfile struct {
+	PortForwardSpec
+	Name string
+	// Restore starts the forward when connecting to the cluster
+	Restore bool
+}
+
+type PortForwardStatus int
+
+const (
+	PortForwardStarting PortForwardStatus = iota
+	PortForwardActive
+	PortForwardReconnecting
+)
+
+func (s PortForwardStatus) String() string {
+	switch s {
+	case PortForwardActive:
+		return "Active"
+	case PortForwardReconnecting:
+		return "Reconnecting"
+	default:
+		return "Starting"
+	}
+}
+
+// PortForward is a snapshot of a running forward.
+type PortForward struct {
+	PortForwardSpec
+	ID        string
+	Status    PortForwardStatus
+	Error     error
+	Forwarded []portforward.ForwardedPort
+	// Health holds the last probe result by remote port. A nil error is
+	// healthy; ports that weren't probed yet are missing.
+	Health map[string]error
+}
+
+// URL returns the local URL of the remote port, or an empty string if the
+// port isn't forwarded.
+func (f PortForward) URL(port string) string {
+	for _, p := range f.Ports {
+		local, remote := SplitPort(p)
+		if remote != port || local == "" {
+			continue
+		}
+		path := f.Paths[port]
+		if path != "" && !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+		host := f.Addresses()[0]
+		if ip := net.ParseIP(host); ip != nil && ip.IsUnspecified() {
+			host = "localhost"
+		}
+		return (&url.URL{Scheme: "http", Host: net.JoinHostPort(host, local), Path: path}).String()
+	}
+	return ""
+}
+
+// Matches returns whether the remote port of the target is part of the
+// forward. Pod targets also match the pod the forward moved to.
+func (f PortForward) Matches(namespace, target, port string) bool {
+	if f.Namespace != namespace || (f.Target != target && (f.Kind() != "pod" || "pod/"+f.Pod != target)) {
+		return false
+	}
+	for _, p := range f.Ports {
+		if _, remote := SplitPort(p); remote == port {
+			return true
+		}
+	}
+	return false
+}
+
+// PortForwards is the registry of all port forwards of a cluster.
+type PortForwards struct {
+	cluster  *Cluster
+	mutex    sync.Mutex
+	forwards []*portForward
+	Forwards pubsub.Property[[]PortForward]
+}
+
+type portForward struct {
+	PortForward
+	cancel context.CancelFunc
+}
+
+func newPortForwards(cluster *Cluster) *PortForwards {
+	return &PortForwards{
+		cluster:  cluster,
+		Forwards: pubsub.NewProperty([]PortForward{}),
+	}
+}
+
+// Start forwards the ports and returns once the first connection is ready.
+// Afterwards, the forward reconnects until it is stopped or the cluster is
+// disconnected.
+func (p *PortForwards) Start(spec PortForwardSpec) (PortForward, error) {
+	if spec.Target == "" {
+		spec.Target = "pod/" + spec.Pod
+	}
+	ctx, cancel := context.WithCancel(p.cluster.ctx)
+	fwd := &portForward{
+		PortForward: PortForward{PortForwardSpec: spec, ID: uuid.NewString()},
+		cancel:      cancel,
+	}
+	fwd.Ports = slices.Clone(spec.Ports)
+
+	ready := make(chan error, 1)
+	go p.run(ctx, fwd, ready)
+
+	var err error
+	select {
+	case err = <-ready:
+	case <-time.After(portForwardTimeout):
+		err = errors.New("timeout")
+	}
+	if err != nil {
+		cancel()
+		return PortForward{}, err
+	}
+
+	p.mutex.Lock()
+	p.forwards = append(p.forwards, fwd)
+	p.mutex.Unlock()
+	p.publish()
+
+	go func() {
+		<-ctx.Done()
+		p.remove(fwd.ID)
+	}()
+
+	return p.get(fwd), nil
+}
+
+// StartProfile starts a saved profile.
+func (p *PortForwards) StartProfile(profile PortForwardProfile) (PortForward, error) {
+	return p.Start(profile.PortForwardSpec)
+}
+
+// Restore starts all profiles that are marked for restoring. It returns the
+// errors of profiles that failed to start, keyed by profile name.
+func (p *PortForwards) Restore() map[string]error {
+	errs := map[string]error{}
+	for _, profile := range p.cluster.ClusterPreferences.Value().PortForwards {
+		if !profile.Restore {
+			continue
+		}
+		if _, err := p.StartProfile(profile); err != nil {
+			errs[profile.Name] = err
+		}
+	}
+	return errs
+}
+
+// Stop stops the forward with the given ID.
+func (p *PortForwards) Stop(id string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for _, fwd := range p.forwards {
+		if fwd.ID == id {
+			fwd.cancel()
+		}
+	}
+}
+
+// StopAll stops all forwards.
+func (p *PortForwards) StopAll() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for _, fwd := range p.forwards {
+		fwd.cancel()
+	}
+}
+
+// Find returns the forward of the remote port of the target, if any.
+func (p *PortForwards) Find(namespace, target, port string) (PortForward, bool) {
+	for _, fwd := range p.List() {
+		if fwd.Matches(namespace, target, port) {
+			return fwd, true
+		}
+	}
+	return PortForward{}, false
+}
+
+func (p *PortForwards) List() []PortForward {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	var list []PortForward
+	for _, fwd := range p.forwards {
+		list = append(list, fwd.snapshot())
+	}
+	return list
+}
+
+func (p *PortForwards) get(fwd *portForward) PortForward {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return fwd.snapshot()
+}
+
+func (p *PortForwards) update(fwd *portForward, fn func(fwd *PortForward)) {
+	p.mutex.Lock()
+	fn(&fwd.PortForward)
+	p.mutex.Unlock()
+	p.publish()
+}
+
+func (p *PortForwards) remove(id string) {
+	p.mutex.Lock()
+	p.forwards = slices.DeleteFunc(p.forwards, func(fwd *portForward) bool { return fwd.ID == id })
+	p.mutex.Unlock()
+	p.publish()
+}
+
+func (p *PortForwards) publish() {
+	p.Forwards.Pub(p.List())
+}
+
+func (f *portForward) snapshot() PortForward {
+	s := f.PortForward
+	s.Ports = slices.Clone(f.Ports)
+	s.Forwarded = slices.Clone(f.Forwarded)
+	s.Health = maps.Clone(f.Health)
+	return s
+}
+
+// run keeps the forward alive until ctx is done. The result of the first
+// attempt is sent to ready; later failures are retried.
+func (p *PortForwards) run(ctx context.Context, fwd *portForward, ready chan<- error) {
+	first := true
+	for {
+		pod, ports, err := p.resolvePod(ctx, fwd)
+		if err == nil {
+			err = p.forward(ctx, fwd, pod, ports, func() {
+				if first {
+					first = false
+					ready <- nil
+				}
+			})
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if first {
+			if err == nil {
+				err = errors.New("connection closed")
+			}
+			ready <- err
+			return
+		}
+		if err != nil {
+			spec := p.get(fwd)
+			klog.Infof("port forward to %s/%s failed: %s", spec.Namespace, spec.Pod, err)
+		}
+		p.update(fwd, func(fwd *PortForward) {
+			fwd.Status = PortForwardReconnecting
+			fwd.Error = err
+		})
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(portForwardRetry):
+		}
+	}
+}
+
+// forward forwards the resolved pod ports until the connection is lost or the
+// pod stops running.
+func (p *PortForwards) forward(ctx context.Context, fwd *portForward, pod *corev1.Pod, ports []string, onReady func()) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	url := p.cluster.CoreV1().RESTClient().Post().Resource("pods").Namespace(pod.Namespace).Name(pod.Name).SubResource("portforward").URL()
+	transport, upgrader, err := spdy.RoundTripperFor(p.cluster.Config())
+	if err != nil {
+		return err
+	}
+	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, http.MethodPost, url)
+	tunnelingDialer, err := portforward.NewSPDYOverWebsocketDialer(url, p.cluster.Config())
+	if err != nil {
+		return err
+	}
+	dialer = portforward.NewFallbackDialer(tunnelingDialer, dialer, httpstream.IsUpgradeFailure)
+
+	readyChan := make(chan struct{})
+	errOut := &forwardLog{namespace: pod.Namespace, pod: pod.Name}
+	forwarder, err := portforward.NewOnAddresses(dialer, p.get(fwd).Addresses(), ports, ctx.Done(), readyChan, nil, errOut)
+	if err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		select {
+		case <-ctx.Done():
+			return
+		case <-readyChan:
+		}
+		forwarded, _ := forwarder.GetPorts()
+		p.update(fwd, func(fwd *PortForward) {
+			fwd.Pod = pod.Name
+			fwd.Status = PortForwardActive
+			fwd.Error = nil
+			fwd.Forwarded = forwarded
+			// Keep the local ports when reconnecting
+			for i, port := range forwarded {
+				if i < len(fwd.Ports) {
+					_, remote := SplitPort(fwd.Ports[i])
+					fwd.Ports[i] = fmt.Sprintf("%d:%s", port.Local, remote)
+				}
+			}
+		})
+		onReady()
+		go p.probe(ctx, fwd)
+		p.watchPod(ctx, pod)
+		cancel()
+	}()
+
+	err = forwarder.ForwardPorts()
+	cancel()
+	<-done
+	return err
+}
+
+// forwardLog logs the error output of a forwarder. These are errors of single
+// connections, e.g. of probes while nothing listens in the pod, which don't
+// end the forward and show up in its health instead. At most one error is
+// logged per interval.
+type forwardLog struct {
+	namespace string
+	pod       string
+	mutex     sync.Mutex
+	last      time.Time
+	dropped   int
+}
+
+func (w *forwardLog) Write(b []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if time.Since(w.last) < portForwardLogInterval {
+		w.dropped++
+		return len(b), nil
+	}
+	msg := strings.TrimSpace(string(b))
+	if w.dropped > 0 {
+		msg = fmt.Sprintf("%s (%d more errors since the last)", msg, w.dropped)
+	}
+	klog.Infof("port forward to %s/%s: %s", w.namespace, w.pod, msg)
+	w.last = time.Now()
+	w.dropped = 0
+	return len(b), nil
+}
+
+// probe periodically checks the local ports. Ports with a path get an HTTP
+// request, others only a TCP connection, which opens a stream to the pod. While
+// ports fail, they're probed less often.
+func (p *PortForwards) probe(ctx context.Context, fwd *portForward) {
+	client := &http.Client{Timeout: portForwardProbeTimeout}
+	interval := portForwardProbeInterval
+	for {
+		spec := p.get(fwd)
+		health := map[string]error{}
+		for _, port := range spec.Ports {
+			_, remote := SplitPort(port)
+			target := spec.URL(remote)
+			if target == "" {
+				continue
+			}
+			if _, ok := spec.Paths[remote]; ok {
+				health[remote] = probeHTTP(ctx, client, target)
+			} else {
+				u, _ := url.Parse(target)
+				health[remote] = probeTCP(ctx, u.Host)
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if !maps.EqualFunc(health, spec.Health, func(a, b error) bool { return (a == nil) == (b == nil) }) {
+			p.update(fwd, func(fwd *PortForward) {
+				fwd.Health = health
+			})
+		}
+
+		failing := false
+		for _, err := range health {
+			failing = failing || err != nil
+		}
+		if failing {
+			interval = min(2*interval, portForwardProbeMaxInterval)
+		} else {
+			interval = portForwardProbeInterval
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+func probeHTTP(ctx context.Context, client *http.Client, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode >= 500 {
+		return fmt.Errorf("HTTP %s", res.Status)
+	}
+	return nil
+}
+
+// probeTCP connects through the forward. The local listener always accepts,
+// but closes the connection right away if nothing listens in the pod.
+func probeTCP(ctx context.Context, host string) error {
+	dialer := net.Dialer{Timeout: portForwardProbeTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err != nil {
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return nil
+		}
+		return fmt.Errorf("connection closed: %w", err)
+	}
+	return nil
+}
+
+// watchPod returns once the pod no longer runs, so the forward can move to a
+// replacement.
+func (p *PortForwards) watchPod(ctx context.Context, pod *corev1.Pod) {
+	ticker := time.NewTicker(portForwardRetry)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		current, err := p.cluster.CoreV1().Pods(pod.Namespace).Get(ctx, pod.Name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) || (err == nil && (current.UID != pod.UID || !podReady(current))) {
+			return
+		}
+	}
+}
+
+// resolvePod returns the pod to forward to and its ports. The current pod is
+// kept while it is ready; otherwise a ready pod of the target is picked.
+func (p *PortForwards) resolvePod(ctx context.Context, fwd *portForward) (*corev1.Pod, []string, error) {
+	spec := p.get(fwd)
+	pods := p.cluster.CoreV1().Pods(spec.Namespace)
+
+	if spec.Kind() == "service" {
+		return p.resolveService(ctx, spec)
+	}
+
+	if spec.Pod != "" {
+		pod, err := pods.Get(ctx, spec.Pod, metav1.GetOptions{})
+		if err == nil && podReady(pod) {
+			return pod, spec.Ports, nil
+		}
+		if len(spec.Selector) == 0 {
+			if err != nil {
+				return nil, nil, err
+			}
+			return nil, nil, fmt.Errorf("pod %s is not ready", spec.Pod)
+		}
+	}
+	if len(spec.Selector) == 0 {
+		return nil, nil, errors.New("no pod or selector")
+	}
+
+	list, err := pods.List(ctx, metav1.ListOptions{LabelSelector: labels.SelectorFromSet(spec.Selector).String()})
+	if err != nil {
+		return nil, nil, err
+	}
+	pod := newestReadyPod(list.Items)
+	if pod == nil {
+		return nil, nil, fmt.Errorf("no ready pod matches %s", labels.SelectorFromSet(spec.Selector))
+	}
+	return pod, spec.Ports, nil
+}
+
+// resolveService picks a ready endpoint of the service, like kubectl
+// port-forward svc/name, and maps the service ports to its container ports.
+func (p *PortForwards) resolveService(ctx context.Context, spec PortForward) (*corev1.Pod, []string, error) {
+	svc, err := p.cluster.CoreV1().Services(spec.Namespace).Get(ctx, spec.Name(), metav1.GetOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var candidates []corev1.Pod
+	endpointSlices, err := p.cluster.DiscoveryV1().EndpointSlices(spec.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(labels.Set{discoveryv1.LabelServiceName: svc.Name}).String(),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, slice := range endpointSlices.Items {
+		for _, endpoint := range slice.Endpoints {
+			if endpoint.TargetRef == nil || endpoint.TargetRef.Kind != "Pod" || (endpoint.Conditions.Ready != nil && !*endpoint.Conditions.Ready) {
+				continue
+			}
+			pod, err := p.cluster.CoreV1().Pods(spec.Namespace).Get(ctx, endpoint.TargetRef.Name, metav1.GetOptions{})
+			if err == nil && podReady(pod) {
+				candidates = append(candidates, *pod)
+			}
+		}
+	}
+	if len(candidates) == 0 && len(svc.Spec.Selector) > 0 {
+		list, err := p.cluster.CoreV1().Pods(spec.Namespace).List(ctx, metav1.ListOptions{LabelSelector: labels.SelectorFromSet(svc.Spec.Selector).String()})
+		if err != nil {
+			return nil, nil, err
+		}
+		candidates = list.Items
+	}
+
+	pod := newestReadyPod(candidates)
+	for i := range candidates {
+		if candidates[i].Name == spec.Pod && podReady(&candidates[i]) {
+			pod = &candidates[i]
+		}
+	}
+	if pod == nil {
+		return nil, nil, fmt.Errorf("service %s has no ready endpoints", svc.Name)
+	}
+
+	var ports []string
+	for _, port := range spec.Ports {
+		local, remote := SplitPort(port)
+		containerPort, err := serviceTargetPort(svc, pod, remote)
+		if err != nil {
+			return nil, nil, err
+		}
+		ports = append(ports, fmt.Sprintf("%s:%d", local, containerPort))
+	}
+	return pod, ports, nil
+}
+
+// serviceTargetPort resolves a service port, by number or name, to the
+// container port of the pod.
+func serviceTargetPort(svc *corev1.Service, pod *corev1.Pod, port string) (int32, error) {
+	for _, sp := range svc.Spec.Ports {
+		if sp.Name != port && strconv.Itoa(int(sp.Port)) != port {
+			continue
+		}
+		switch {
+		case sp.TargetPort.Type == intstr.String:
+			for _, c := range pod.Spec.Containers {
+				for _, cp := range c.Ports {
+					if cp.Name == sp.TargetPort.StrVal {
+						return cp.ContainerPort, nil
+					}
+				}
+			}
+			return 0, fmt.Errorf("pod %s has no port named %s", pod.Name, sp.TargetPort.StrVal)
+		case sp.TargetPort.IntVal != 0:
+			return sp.TargetPort.IntVal, nil
+		default:
+			return sp.Port, nil
+		}
+	}
+	return 0, fmt.Errorf("service %s has no port %s", svc.Name, port)
+}
+
+func newestReadyPod(pods []corev1.Pod) *corev1.Pod {
+	var pod *corev1.Pod
+	for i, item := range pods {
+		if podReady(&item) && (pod == nil || item.CreationTimestamp.After(pod.CreationTimestamp.Time)) {
+			pod = &pods[i]
+		}
+	}
+	return pod
+}
+
+// PodSelector derives a selector for pods of the same workload from the
+// pod's labels. Bare pods have no selector.
+func PodSelector(pod *corev1.Pod) map[string]string {
+	if len(pod.OwnerReferences) == 0 {
+		return nil
+	}
+	selector := map[string]string{}
+	for key, value := range pod.Labels {
+		if !slices.Contains(podHashLabels, key) {
+			selector[key] = value
+		}
+	}
+	if len(selector) == 0 {
+		return nil
+	}
+	return selector
+}
+
+func podReady(pod *corev1.Pod) bool {
+	if pod.DeletionTimestamp != nil || pod.Status.Phase != corev1.PodRunning {
+		return false
+	}
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == corev1.PodReady {
+			return cond.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// SplitPort splits a port in kubectl notation into its local and remote
+// part. The local part is empty if unset.
+func SplitPort(port string) (string, string) {
+	if i := strings.LastIndex(port, ":"); i >= 0 {
+		return port[:i], port[i+1:]
+	}
+	return "", port
+}
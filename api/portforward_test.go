@@ -0,0 +1,108 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestSplitPort(t *testing.T) {
+	tests := []struct {
+		port       string
+		wantLocal  string
+		wantRemote string
+	}{
+		{"8080", "", "8080"},
+		{"9090:8080", "9090", "8080"},
+		{":8080", "", "8080"},
+		{"0:http", "0", "http"},
+	}
+	for _, tt := range tests {
+		local, remote := SplitPort(tt.port)
+		if local != tt.wantLocal || remote != tt.wantRemote {
+			t.Errorf("SplitPort(%q) = %q, %q, want %q, %q", tt.port, local, remote, tt.wantLocal, tt.wantRemote)
+		}
+	}
+}
+
+func TestPodSelector(t *testing.T) {
+	owner := []metav1.OwnerReference{{Kind: "ReplicaSet", Name: "web-5d4f"}}
+	tests := []struct {
+		name string
+		pod  metav1.ObjectMeta
+		want map[string]string
+	}{
+		{
+			name: "without owner",
+			pod:  metav1.ObjectMeta{Labels: map[string]string{"app": "web"}},
+		},
+		{
+			name: "drops hash labels",
+			pod: metav1.ObjectMeta{OwnerReferences: owner, Labels: map[string]string{
+				"app":                                "web",
+				"pod-template-hash":                  "5d4f",
+				"controller-revision-hash":           "web-7c9",
+				"statefulset.kubernetes.io/pod-name": "web-0",
+				"apps.kubernetes.io/pod-index":       "0",
+			}},
+			want: map[string]string{"app": "web"},
+		},
+		{
+			name: "only hash labels",
+			pod:  metav1.ObjectMeta{OwnerReferences: owner, Labels: map[string]string{"pod-template-hash": "5d4f"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PodSelector(&corev1.Pod{ObjectMeta: tt.pod}); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("PodSelector() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServiceTargetPort(t *testing.T) {
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "web"},
+		Spec: corev1.ServiceSpec{Ports: []corev1.ServicePort{
+			{Name: "http", Port: 80, TargetPort: intstr.FromString("web")},
+			{Name: "metrics", Port: 9090, TargetPort: intstr.FromInt32(9091)},
+			{Name: "grpc", Port: 50051},
+			{Name: "admin", Port: 8081, TargetPort: intstr.FromString("missing")},
+		}},
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-0"},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{
+			{Name: "sidecar"},
+			{Name: "web", Ports: []corev1.ContainerPort{{Name: "web", ContainerPort: 8080}}},
+		}},
+	}
+	tests := []struct {
+		port    string
+		want    int32
+		wantErr bool
+	}{
+		{port: "http", want: 8080},
+		{port: "80", want: 8080},
+		{port: "metrics", want: 9091},
+		{port: "9090", want: 9091},
+		{port: "grpc", want: 50051},
+		{port: "admin", wantErr: true},
+		{port: "443", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.port, func(t *testing.T) {
+			got, err := serviceTargetPort(svc, pod, tt.port)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("serviceTargetPort() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("serviceTargetPort() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
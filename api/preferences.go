@@ -3,8 +3,14 @@ package api
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
 	"os"
 	"path"
+	"reflect"
+	"slices"
 	"strings"
 	"time"
 
@@ -17,6 +23,7 @@ import (
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/tools/clientcmd/api"
+	"k8s.io/klog/v2"
 )
 
 type basePreferences struct {
@@ -45,11 +52,16 @@ func prefsPath() string {
 }
 
 type ClusterPreferences struct {
-	Kubeconfig          *Kubeconfig
-	Name                string
-	Host                string
-	BearerToken         string
-	TLS                 rest.TLSClientConfig
+	Kubeconfig  *Kubeconfig
+	Name        string
+	Host        string
+	BearerToken string
+	// TLS.ServerName overrides the server name used to verify the certificate
+	TLS rest.TLSClientConfig
+	// ProxyURL is a http, https or socks5 proxy. If empty, the proxy
+	// environment variables are used, unless NoProxy is set.
+	ProxyURL            string
+	NoProxy             bool
 	Exec                *api.ExecConfig
 	ReadOnly            bool
 	SkipTlsVerification bool
@@ -57,11 +69,41 @@ type ClusterPreferences struct {
 		Favourites []schema.GroupVersionResource
 		Pins       []corev1.ObjectReference
 	}
+	Terminal struct {
+		// Shells are probed in order when opening a terminal
+		Shells []string
+		// History holds recently used commands per container
+		History map[string][]string
+		// DebugImage is used for ephemeral debug containers
+		DebugImage string
+		// NodeShellImage and NodeShellNamespace are used for the privileged
+		// pods that provide node shells
+		NodeShellImage     string
+		NodeShellNamespace string
+		// Record enables asciicast recordings of all terminal sessions
+		Record          bool
+		RecordDirectory string
+	}
+	PortForwards []PortForwardProfile
+	// Impersonate is sent with all requests, to see the cluster as another
+	// user or service account
+	Impersonate rest.ImpersonationConfig
+	// Namespaces restricts informers to these namespaces, for users that
+	// can't list cluster-wide. If empty, it's detected when connecting.
+	Namespaces []string
 }
 
+const maxCommandHistory = 20
+
+var defaultShells = []string{"/bin/bash", "/bin/sh", "/bin/ash"}
+
 type Kubeconfig struct {
 	Path    string
 	Context string
+	// Namespace is the namespace of the context
+	Namespace string
+	// AuthInfo is the user of the context
+	AuthInfo string
 }
 
 func LoadPreferences() (*Preferences, error) {
@@ -95,43 +137,101 @@ func LoadPreferences() (*Preferences, error) {
 		}
 	}
 
+	for _, path := range KubeconfigPaths() {
+		base.Clusters = append(base.Clusters, kubeconfigClusters(path, base.Clusters)...)
+	}
+
+	prefs := Preferences{
+		basePreferences: &base,
+	}
+
+	for _, cluster := range base.Clusters {
+		prefs.Clusters = append(prefs.Clusters, pubsub.NewProperty(cluster))
+	}
+
+	return &prefs, nil
+}
+
+// KubeconfigPaths returns the kubeconfig files that clusters are loaded from
+// by default.
+func KubeconfigPaths() []string {
 	home, _ := os.UserHomeDir()
+	var paths []string
 	for _, path := range append([]string{path.Join(home, ".kube/config")}, strings.Split(os.Getenv("KUBECONFIG"), ":")...) {
-		if _, err := os.Stat(path); err != nil {
-			continue
+		if path != "" && !slices.Contains(paths, path) {
+			paths = append(paths, path)
 		}
+	}
+	return paths
+}
 
-		config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(&clientcmd.ClientConfigLoadingRules{ExplicitPath: path}, nil).
-			ConfigAccess().GetStartingConfig()
-		if err != nil {
-			continue
-		}
+// kubeconfigClusters returns clusters for the contexts of the kubeconfig file
+// that aren't in clusters yet.
+func kubeconfigClusters(path string, clusters []ClusterPreferences) []ClusterPreferences {
+	if _, err := os.Stat(path); err != nil {
+		return nil
+	}
 
-	context:
-		for context := range config.Contexts {
-			for _, c := range base.Clusters {
-				if c.Kubeconfig != nil && c.Kubeconfig.Path == path && c.Kubeconfig.Context == context {
-					continue context
-				}
-			}
-			prefs := ClusterPreferences{Kubeconfig: &Kubeconfig{Path: path, Context: context}}
-			prefs.Defaults()
-			if err := UpdateClusterPreferences(&prefs, path, context); err == nil {
-				base.Clusters = append(base.Clusters, prefs)
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(&clientcmd.ClientConfigLoadingRules{ExplicitPath: path}, nil).
+		ConfigAccess().GetStartingConfig()
+	if err != nil {
+		return nil
+	}
+
+	var added []ClusterPreferences
+context:
+	for context := range config.Contexts {
+		for _, c := range clusters {
+			if c.Kubeconfig != nil && c.Kubeconfig.Path == path && c.Kubeconfig.Context == context {
+				continue context
 			}
 		}
-
+		prefs := ClusterPreferences{Kubeconfig: &Kubeconfig{Path: path, Context: context}}
+		prefs.Defaults()
+		if err := UpdateClusterPreferences(&prefs, path, context); err == nil {
+			added = append(added, prefs)
+		}
 	}
+	return added
+}
 
-	prefs := Preferences{
-		basePreferences: &base,
+// ReloadKubeconfig updates the clusters that were loaded from the kubeconfig
+// file, so connected clusters get refreshed credentials, and adds clusters for
+// new contexts. It returns whether clusters were added.
+func (c *Preferences) ReloadKubeconfig(path string) bool {
+	var clusters []ClusterPreferences
+	for _, cluster := range c.Clusters {
+		prefs := cluster.Value()
+		clusters = append(clusters, prefs)
+		if prefs.Kubeconfig == nil || prefs.Kubeconfig.Path != path {
+			continue
+		}
+		updated := prefs.clone()
+		if err := UpdateClusterPreferences(&updated, path, prefs.Kubeconfig.Context); err != nil {
+			klog.Infof("reloading context '%s' from %s: %s", prefs.Kubeconfig.Context, path, err)
+			continue
+		}
+		if !reflect.DeepEqual(prefs, updated) {
+			cluster.Pub(updated)
+		}
 	}
 
-	for _, cluster := range base.Clusters {
-		prefs.Clusters = append(prefs.Clusters, pubsub.NewProperty(cluster))
+	added := kubeconfigClusters(path, clusters)
+	for _, prefs := range added {
+		c.Clusters = append(c.Clusters, pubsub.NewProperty(prefs))
 	}
+	return len(added) > 0
+}
 
-	return &prefs, nil
+// clone copies the preferences along with the pointer fields, so the copy can
+// be updated without changing published preferences.
+func (c ClusterPreferences) clone() ClusterPreferences {
+	if c.Kubeconfig != nil {
+		kubeconfig := *c.Kubeconfig
+		c.Kubeconfig = &kubeconfig
+	}
+	c.Exec = c.Exec.DeepCopy()
+	return c
 }
 
 func (c *basePreferences) Defaults() {
@@ -141,6 +241,18 @@ func (c *basePreferences) Defaults() {
 }
 
 func (c *ClusterPreferences) Defaults() {
+	if len(c.Terminal.Shells) == 0 {
+		c.Terminal.Shells = defaultShells
+	}
+	if c.Terminal.DebugImage == "" {
+		c.Terminal.DebugImage = "busybox:latest"
+	}
+	if c.Terminal.NodeShellImage == "" {
+		c.Terminal.NodeShellImage = "busybox:latest"
+	}
+	if c.Terminal.NodeShellNamespace == "" {
+		c.Terminal.NodeShellNamespace = "default"
+	}
 	if len(c.Navigation.Favourites) == 0 {
 		c.Navigation.Favourites = []schema.GroupVersionResource{
 			{
@@ -197,6 +309,47 @@ func (c *ClusterPreferences) Defaults() {
 	}
 }
 
+// Proxy returns the proxy function for the cluster's rest.Config.
+func (c *ClusterPreferences) Proxy() (func(*http.Request) (*url.URL, error), error) {
+	if c.ProxyURL != "" {
+		u, err := url.Parse(c.ProxyURL)
+		if err != nil {
+			return nil, err
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return nil, fmt.Errorf("unsupported proxy scheme '%s'", u.Scheme)
+		}
+		return http.ProxyURL(u), nil
+	}
+	if c.NoProxy {
+		return nil, nil
+	}
+	return http.ProxyFromEnvironment, nil
+}
+
+// Impersonating returns whether requests are made as another user.
+func (c *ClusterPreferences) Impersonating() bool {
+	return c.Impersonate.UserName != "" || c.Impersonate.UID != "" || len(c.Impersonate.Groups) > 0 || len(c.Impersonate.Extra) > 0
+}
+
+// AddCommandHistory moves the command to the front of the history for key.
+func (c *ClusterPreferences) AddCommandHistory(key, command string) {
+	history := maps.Clone(c.Terminal.History)
+	if history == nil {
+		history = map[string][]string{}
+	}
+	commands := []string{command}
+	for _, cmd := range history[key] {
+		if cmd != command && len(commands) < maxCommandHistory {
+			commands = append(commands, cmd)
+		}
+	}
+	history[key] = commands
+	c.Terminal.History = history
+}
+
 func (c *Preferences) Save() error {
 	if err := os.MkdirAll(path.Dir(prefsPath()), os.ModePerm); err != nil {
 		return err
@@ -231,6 +384,22 @@ func UpdateClusterPreferences(prefs *ClusterPreferences, path, context string) e
 
 	prefs.Host = config.Host
 	prefs.Exec = config.ExecProvider
+	prefs.ProxyURL = ""
+	if raw, err := cc.RawConfig(); err == nil {
+		name := raw.CurrentContext
+		if context != "" {
+			name = context
+		}
+		if ctx := raw.Contexts[name]; ctx != nil {
+			if prefs.Kubeconfig != nil {
+				prefs.Kubeconfig.Namespace = ctx.Namespace
+				prefs.Kubeconfig.AuthInfo = ctx.AuthInfo
+			}
+			if cluster := raw.Clusters[ctx.Cluster]; cluster != nil {
+				prefs.ProxyURL = cluster.ProxyURL
+			}
+		}
+	}
 	prefs.TLS = config.TLSClientConfig
 	prefs.SkipTlsVerification = config.TLSClientConfig.Insecure
 
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestAddCommandHistory(t *testing.T) {
+	var full []string
+	for i := 0; i < maxCommandHistory; i++ {
+		full = append(full, fmt.Sprintf("cmd%d", i))
+	}
+
+	tests := []struct {
+		name    string
+		history map[string][]string
+		key     string
+		command string
+		want    map[string][]string
+	}{
+		{
+			name:    "empty",
+			key:     "a",
+			command: "bash",
+			want:    map[string][]string{"a": {"bash"}},
+		},
+		{
+			name:    "prepends",
+			history: map[string][]string{"a": {"sh"}},
+			key:     "a",
+			command: "bash",
+			want:    map[string][]string{"a": {"bash", "sh"}},
+		},
+		{
+			name:    "moves existing command to front",
+			history: map[string][]string{"a": {"sh", "top", "bash"}},
+			key:     "a",
+			command: "bash",
+			want:    map[string][]string{"a": {"bash", "sh", "top"}},
+		},
+		{
+			name:    "keeps other keys",
+			history: map[string][]string{"b": {"sh"}},
+			key:     "a",
+			command: "bash",
+			want:    map[string][]string{"a": {"bash"}, "b": {"sh"}},
+		},
+		{
+			name:    "drops oldest beyond limit",
+			history: map[string][]string{"a": full},
+			key:     "a",
+			command: "bash",
+			want:    map[string][]string{"a": append([]string{"bash"}, full[:maxCommandHistory-1]...)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var prefs ClusterPreferences
+			prefs.Terminal.History = tt.history
+			original := fmt.Sprint(tt.history)
+			prefs.AddCommandHistory(tt.key, tt.command)
+			if !reflect.DeepEqual(prefs.Terminal.History, tt.want) {
+				t.Errorf("History = %v, want %v", prefs.Terminal.History, tt.want)
+			}
+			// Preferences are shared through properties, so the map isn't modified
+			if fmt.Sprint(tt.history) != original {
+				t.Errorf("original history modified: %v", tt.history)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"maps"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// informerFailureThreshold is how many watch errors in a row make an informer
+// show up as failing. Single errors are usually retried successfully.
+const informerFailureThreshold = 3
+
+// ConnectionReport lists what doesn't work on a connected cluster, so resources
+// that are missing or stale can be explained.
+type ConnectionReport struct {
+	// FailedGroups are API groups whose discovery failed, e.g. because an
+	// aggregated API server is down. Their resources are missing.
+	FailedGroups map[schema.GroupVersion]error
+	// Disabled are subsystems that are unavailable, by name.
+	Disabled map[string]error
+	// Informers are informers whose watches keep failing.
+	Informers map[schema.GroupVersionResource]InformerFailure
+}
+
+type InformerFailure struct {
+	Failures int
+	Err      error
+	Time     time.Time
+	// resourceVersion is the last synced resource version at the time of the
+	// error. If it changed, the informer synced in between.
+	resourceVersion string
+}
+
+// Problems is the number of failed groups and failing informers. Disabled
+// subsystems are not counted, as e.g. many clusters have no metrics server.
+func (r ConnectionReport) Problems() int {
+	return len(r.FailedGroups) + len(r.Informers)
+}
+
+func (r ConnectionReport) clone() ConnectionReport {
+	return ConnectionReport{
+		FailedGroups: maps.Clone(r.FailedGroups),
+		Disabled:     maps.Clone(r.Disabled),
+		Informers:    maps.Clone(r.Informers),
+	}
+}
+
+func (cluster *Cluster) updateReport(fn func(report *ConnectionReport)) {
+	cluster.reportMutex.Lock()
+	defer cluster.reportMutex.Unlock()
+	report := cluster.Report.Value().clone()
+	fn(&report)
+	cluster.Report.Pub(report)
+}
+
+func (cluster *Cluster) setFailedGroups(groups map[schema.GroupVersion]error) {
+	cluster.updateReport(func(report *ConnectionReport) {
+		report.FailedGroups = groups
+	})
+}
+
+func (cluster *Cluster) setDisabled(name string, err error) {
+	cluster.updateReport(func(report *ConnectionReport) {
+		if err == nil {
+			delete(report.Disabled, name)
+			return
+		}
+		if report.Disabled == nil {
+			report.Disabled = map[string]error{}
+		}
+		report.Disabled[name] = err
+	})
+}
+
+// recordWatchError counts the watch errors of an informer since it last
+// synced. Errors that are part of normal watch operation, like expired
+// resource versions, are ignored.
+func (cluster *Cluster) recordWatchError(gvr schema.GroupVersionResource, resourceVersion string, err error) {
+	if apierrors.IsResourceExpired(err) || apierrors.IsGone(err) || errors.Is(err, io.EOF) {
+		return
+	}
+	cluster.reportMutex.Lock()
+	failure := cluster.watchErrors[gvr]
+	if failure.resourceVersion != resourceVersion {
+		failure.Failures = 0
+	}
+	failure.resourceVersion = resourceVersion
+	failure.Failures++
+	failure.Err = err
+	failure.Time = time.Now()
+	cluster.watchErrors[gvr] = failure
+	cluster.reportMutex.Unlock()
+
+	_, reported := cluster.Report.Value().Informers[gvr]
+	if failure.Failures < informerFailureThreshold && !reported {
+		return
+	}
+	cluster.updateReport(func(report *ConnectionReport) {
+		if failure.Failures < informerFailureThreshold {
+			delete(report.Informers, gvr)
+			return
+		}
+		if report.Informers == nil {
+			report.Informers = map[schema.GroupVersionResource]InformerFailure{}
+		}
+		report.Informers[gvr] = failure
+	})
+}
+
+// recordWatchSync clears the watch errors of an informer once it synced again.
+// Recovered watches don't report anything, so this is called when events are
+// delivered and periodically with the last synced resource version.
+func (cluster *Cluster) recordWatchSync(gvr schema.GroupVersionResource, resourceVersion string) {
+	cluster.reportMutex.Lock()
+	failure, ok := cluster.watchErrors[gvr]
+	if !ok || failure.resourceVersion == resourceVersion {
+		cluster.reportMutex.Unlock()
+		return
+	}
+	delete(cluster.watchErrors, gvr)
+	cluster.reportMutex.Unlock()
+
+	if _, reported := cluster.Report.Value().Informers[gvr]; !reported {
+		return
+	}
+	cluster.updateReport(func(report *ConnectionReport) {
+		delete(report.Informers, gvr)
+	})
+}
+
+// checkWatchRecovery clears the errors of informers that synced since, e.g.
+// of resources without objects, whose relist delivers no events.
+func (cluster *Cluster) checkWatchRecovery() {
+	cluster.reportMutex.Lock()
+	var failing []schema.GroupVersionResource
+	for gvr := range cluster.watchErrors {
+		failing = append(failing, gvr)
+	}
+	cluster.reportMutex.Unlock()
+
+	for _, gvr := range failing {
+		cluster.informerMutex.Lock()
+		informer, ok := cluster.sharedInformers[gvr]
+		cluster.informerMutex.Unlock()
+		if ok {
+			cluster.recordWatchSync(gvr, informer.Informer().LastSyncResourceVersion())
+		}
+	}
+}
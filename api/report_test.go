@@ -0,0 +1,89 @@
+package api
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/getseabird/seabird/internal/pubsub"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestWatchErrorRecovery(t *testing.T) {
+	pods := schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+	refused := errors.New("connection refused")
+
+	type step struct {
+		// err records a watch error, otherwise a sync is recorded
+		err             error
+		resourceVersion string
+		wantReported    bool
+		wantErrors      int
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "below threshold",
+			steps: []step{
+				{err: refused, resourceVersion: "1", wantErrors: 1},
+				{err: refused, resourceVersion: "1", wantErrors: 1},
+			},
+		},
+		{
+			name: "reported at threshold",
+			steps: []step{
+				{err: refused, resourceVersion: "1", wantErrors: 1},
+				{err: refused, resourceVersion: "1", wantErrors: 1},
+				{err: refused, resourceVersion: "1", wantReported: true, wantErrors: 1},
+			},
+		},
+		{
+			name: "cleared once synced",
+			steps: []step{
+				{err: refused, resourceVersion: "1", wantErrors: 1},
+				{err: refused, resourceVersion: "1", wantErrors: 1},
+				{err: refused, resourceVersion: "1", wantReported: true, wantErrors: 1},
+				{resourceVersion: "1", wantReported: true, wantErrors: 1},
+				{resourceVersion: "2"},
+			},
+		},
+		{
+			name: "counted again after recovery",
+			steps: []step{
+				{err: refused, resourceVersion: "1", wantErrors: 1},
+				{err: refused, resourceVersion: "1", wantErrors: 1},
+				{resourceVersion: "2"},
+				{err: refused, resourceVersion: "2", wantErrors: 1},
+				{err: refused, resourceVersion: "2", wantErrors: 1},
+			},
+		},
+		{
+			name: "sync without errors",
+			steps: []step{
+				{resourceVersion: "1"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := &Cluster{
+				Report:      pubsub.NewProperty(ConnectionReport{}),
+				watchErrors: map[schema.GroupVersionResource]InformerFailure{},
+			}
+			for i, step := range tt.steps {
+				if step.err != nil {
+					cluster.recordWatchError(pods, step.resourceVersion, step.err)
+				} else {
+					cluster.recordWatchSync(pods, step.resourceVersion)
+				}
+				if _, reported := cluster.Report.Value().Informers[pods]; reported != step.wantReported {
+					t.Errorf("step %d: reported = %v, want %v", i, reported, step.wantReported)
+				}
+				if len(cluster.watchErrors) != step.wantErrors {
+					t.Errorf("step %d: watch errors = %v, want %d", i, cluster.watchErrors, step.wantErrors)
+				}
+			}
+		})
+	}
+}
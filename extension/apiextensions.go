@@ -5,11 +5,17 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/getseabird/seabird/api"
 	"github.com/getseabird/seabird/internal/pubsub"
+	"github.com/zmwangx/debounce"
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/util/jsonpath"
 	"k8s.io/klog/v2"
 	"k8s.io/utils/ptr"
@@ -25,15 +31,50 @@ func NewApiextensions(ctx context.Context, cluster *api.Cluster) (Extension, err
 		return nil, err
 	}
 
-	// TODO apiextensions v1 is not part of default client-go
-	// api.InformerConnectProperty(ctx, cluster, apiextensionsv1.SchemeGroupVersion.WithResource("customresourcedefinitions"), crds)
-
-	var crds apiextensionsv1.CustomResourceDefinitionList
-	if err := cluster.Client.List(ctx, &crds); err != nil {
+	var list apiextensionsv1.CustomResourceDefinitionList
+	if err := cluster.Client.List(ctx, &list); err != nil {
+		return nil, err
+	}
+	crds := pubsub.NewProperty(list.Items)
+
+	// apiextensions v1 is not part of default client-go, so CRDs come from a
+	// dynamic informer and are converted
+	gvr := apiextensionsv1.SchemeGroupVersion.WithResource("customresourcedefinitions")
+	update, _ := debounce.Debounce(func() {
+		var items []apiextensionsv1.CustomResourceDefinition
+		err := cache.ListAll(cluster.GetInformer(gvr).Informer().GetIndexer(), labels.Everything(), func(m interface{}) {
+			u, ok := m.(*unstructured.Unstructured)
+			if !ok {
+				return
+			}
+			var crd apiextensionsv1.CustomResourceDefinition
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, &crd); err != nil {
+				klog.Warningf("convert crd '%s': %s", u.GetName(), err)
+				return
+			}
+			items = append(items, crd)
+		})
+		if err != nil {
+			klog.Warningf("list crds: %s", err)
+			return
+		}
+		crds.Pub(items)
+	}, 100*time.Millisecond)
+	if err := cluster.AddInformerEventHandler(ctx, gvr, cache.ResourceEventHandlerFuncs{
+		AddFunc: func(_ interface{}) {
+			update()
+		},
+		UpdateFunc: func(_, _ interface{}) {
+			update()
+		},
+		DeleteFunc: func(_ interface{}) {
+			update()
+		},
+	}); err != nil {
 		return nil, err
 	}
 
-	return &Apiextensions{Cluster: cluster, crds: pubsub.NewProperty(crds.Items)}, nil
+	return &Apiextensions{Cluster: cluster, crds: crds}, nil
 }
 
 type Apiextensions struct {
@@ -3,6 +3,7 @@ package extension
 import (
 	"context"
 	"fmt"
+	"strconv"
 
 	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
 	"github.com/diamondburned/gotk4/pkg/gtk/v4"
@@ -95,7 +96,7 @@ func (e *Apps) CreateColumns(ctx context.Context, resource *metav1.APIResource,
 func (e *Apps) CreateObjectProperties(ctx context.Context, _ *metav1.APIResource, object client.Object, props []api.Property) []api.Property {
 	switch object := object.(type) {
 	case *appsv1.Deployment:
-		prop := &api.GroupProperty{Name: "Pods"}
+		prop := &api.GroupProperty{Name: "Pods", Widget: podLogsWidget(ctx, e.Cluster, object, object.Spec.Selector)}
 		var pods corev1.PodList
 		e.List(ctx, &pods, client.InNamespace(object.Namespace), client.MatchingLabels(object.Spec.Selector.MatchLabels))
 		for i, pod := range pods.Items {
@@ -113,8 +114,11 @@ func (e *Apps) CreateObjectProperties(ctx context.Context, _ *metav1.APIResource
 			})
 		}
 		props = append(props, prop)
+		if ports := e.createPortsProperty(ctx, object, object.Spec.Template.Spec); len(ports.Children) > 0 {
+			props = append(props, ports)
+		}
 	case *appsv1.ReplicaSet:
-		prop := &api.GroupProperty{Name: "Pods"}
+		prop := &api.GroupProperty{Name: "Pods", Widget: podLogsWidget(ctx, e.Cluster, object, object.Spec.Selector)}
 		var pods corev1.PodList
 		e.List(ctx, &pods, client.InNamespace(object.Namespace), client.MatchingLabels(object.Spec.Selector.MatchLabels))
 		// TODO should we also filter pods by owner? takes one more api call to fetch replicasets
@@ -134,7 +138,7 @@ func (e *Apps) CreateObjectProperties(ctx context.Context, _ *metav1.APIResource
 		}
 		props = append(props, prop)
 	case *appsv1.StatefulSet:
-		podsProp := &api.GroupProperty{Name: "Pods"}
+		podsProp := &api.GroupProperty{Name: "Pods", Widget: podLogsWidget(ctx, e.Cluster, object, object.Spec.Selector)}
 		var pods corev1.PodList
 		e.List(ctx, &pods, client.InNamespace(object.Namespace), client.MatchingLabels(object.Spec.Selector.MatchLabels))
 		for i, pod := range pods.Items {
@@ -161,6 +165,9 @@ func (e *Apps) CreateObjectProperties(ctx context.Context, _ *metav1.APIResource
 			})
 		}
 		props = append(props, podsProp)
+		if ports := e.createPortsProperty(ctx, object, object.Spec.Template.Spec); len(ports.Children) > 0 {
+			props = append(props, ports)
+		}
 
 		if len(object.Spec.VolumeClaimTemplates) > 0 {
 			claimProp := &api.GroupProperty{Name: "Volume Claims"}
@@ -195,3 +202,31 @@ func (e *Apps) CreateObjectProperties(ctx context.Context, _ *metav1.APIResource
 
 	return props
 }
+
+// createPortsProperty lists the container ports of the pod template. They can
+// be forwarded to a ready pod of the workload.
+func (e *Apps) createPortsProperty(ctx context.Context, object client.Object, spec corev1.PodSpec) *api.GroupProperty {
+	prop := &api.GroupProperty{Name: "Ports"}
+	for _, container := range spec.Containers {
+		for _, port := range container.Ports {
+			if port.Protocol != "" && port.Protocol != corev1.ProtocolTCP {
+				continue
+			}
+			name := container.Name
+			if port.Name != "" {
+				name = fmt.Sprintf("%s/%s", container.Name, port.Name)
+			}
+			prop.Children = append(prop.Children, &api.TextProperty{
+				Name:  name,
+				Value: strconv.Itoa(int(port.ContainerPort)),
+				Widget: func(w gtk.Widgetter, nv *adw.NavigationView) {
+					switch row := w.(type) {
+					case *adw.ActionRow:
+						row.AddSuffix(newPortForwardBox(ctx, e.Cluster, portForwardSpec(object), strconv.Itoa(int(port.ContainerPort)), tcpPorts(spec)))
+					}
+				},
+			})
+		}
+	}
+	return prop
+}
@@ -97,7 +97,7 @@ func (e *Batch) CreateObjectProperties(ctx context.Context, resource *metav1.API
 			},
 		}})
 
-		prop := &api.GroupProperty{Name: "Pods"}
+		prop := &api.GroupProperty{Name: "Pods", Widget: podLogsWidget(ctx, e.Cluster, object, object.Spec.Selector)}
 		var pods corev1.PodList
 		e.List(ctx, &pods, client.InNamespace(object.Namespace), client.MatchingLabels(object.Spec.Selector.MatchLabels))
 		for i, pod := range pods.Items {
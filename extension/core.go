@@ -3,6 +3,7 @@ package extension
 import (
 	"context"
 	"fmt"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -17,7 +18,6 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/client-go/tools/portforward"
 	"k8s.io/client-go/tools/reference"
 	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
 	"k8s.io/utils/ptr"
@@ -30,15 +30,13 @@ func init() {
 
 func NewCore(_ context.Context, cluster *api.Cluster) (Extension, error) {
 	return &Core{
-		Cluster:     cluster,
-		portforward: PortForwarder{cluster, map[types.NamespacedName]*portforward.PortForwarder{}},
+		Cluster: cluster,
 	}, nil
 }
 
 type Core struct {
 	Noop
 	*api.Cluster
-	portforward PortForwarder
 }
 
 func (e *Core) CreateColumns(ctx context.Context, res *metav1.APIResource, columns []api.Column) []api.Column {
@@ -279,15 +277,23 @@ func (e *Core) CreateObjectProperties(ctx context.Context, _ *metav1.APIResource
 	case *corev1.Pod:
 		var containers []api.Property
 
-		for i, container := range object.Spec.Containers {
+		all := slices.Clone(object.Spec.Containers)
+		for _, ephemeral := range object.Spec.EphemeralContainers {
+			all = append(all, corev1.Container(ephemeral.EphemeralContainerCommon))
+		}
+		for i, container := range all {
 			var props []api.Property
 			var status corev1.ContainerStatus
-			for _, s := range object.Status.ContainerStatuses {
+			for _, s := range slices.Concat(object.Status.ContainerStatuses, object.Status.EphemeralContainerStatuses) {
 				if s.Name == container.Name {
 					status = s
 					break
 				}
 			}
+			name := container.Name
+			if i >= len(object.Spec.Containers) {
+				name = fmt.Sprintf("%s (ephemeral)", container.Name)
+			}
 
 			podMetrics := e.Metrics.Pod(types.NamespacedName{Name: object.Name, Namespace: object.Namespace})
 			var metrics *metricsv1beta1.ContainerMetrics
@@ -362,12 +368,9 @@ func (e *Core) CreateObjectProperties(ctx context.Context, _ *metav1.APIResource
 					Name:  port.Name,
 					Value: fmt.Sprintf("%d", port.ContainerPort),
 					Widget: func(w gtk.Widgetter, nv *adw.NavigationView) {
-						name := types.NamespacedName{Name: object.Name, Namespace: object.Namespace}
 						switch box := w.(type) {
 						case *gtk.Box:
-							btn := gtk.NewButton()
-							e.portforward.UpdateButton(ctx, btn, name, []string{fmt.Sprintf(":%d", port.ContainerPort)})
-							box.Append(btn)
+							box.Append(newPortForwardBox(ctx, e.Cluster, portForwardSpec(object), strconv.Itoa(int(port.ContainerPort)), tcpPorts(object.Spec)))
 						}
 					},
 				})
@@ -404,7 +407,7 @@ func (e *Core) CreateObjectProperties(ctx context.Context, _ *metav1.APIResource
 
 			containers = append(containers, &api.GroupProperty{
 				ID:   fmt.Sprintf("containers.%d", i),
-				Name: container.Name, Children: props,
+				Name: name, Children: props,
 				Widget: func(w gtk.Widgetter, nav *adw.NavigationView) {
 					switch row := w.(type) {
 					case *adw.ExpanderRow:
@@ -444,7 +447,53 @@ func (e *Core) CreateObjectProperties(ctx context.Context, _ *metav1.APIResource
 								nav.Push(widget.NewTerminalPage(ctx, e.Cluster, object, container.Name).NavigationPage)
 							})
 							row.AddRow(exec)
+
+							attach := adw.NewActionRow()
+							attach.SetActivatable(true)
+							attach.AddSuffix(gtk.NewImageFromIconName("go-next-symbolic"))
+							attach.SetTitle("Attach")
+							attach.SetSubtitle("Connect to the main process")
+							attach.ConnectActivated(func() {
+								nav.Push(widget.NewAttachPage(ctx, e.Cluster, object, container.Name).NavigationPage)
+							})
+							row.AddRow(attach)
+
+							debug := adw.NewActionRow()
+							debug.SetActivatable(true)
+							debug.AddSuffix(gtk.NewImageFromIconName("go-next-symbolic"))
+							debug.SetTitle("Debug")
+							debug.SetSubtitle("Start an ephemeral debug container")
+							debug.ConnectActivated(func() {
+								widget.ShowDebugDialog(ctx, e.Cluster, object, container.Name, nav)
+							})
+							row.AddRow(debug)
+							e.ClusterPreferences.Sub(ctx, func(prefs api.ClusterPreferences) {
+								exec.SetVisible(!prefs.ReadOnly)
+								attach.SetVisible(!prefs.ReadOnly)
+								debug.SetVisible(!prefs.ReadOnly)
+							})
 						}
+
+						upload := adw.NewActionRow()
+						upload.SetActivatable(true)
+						upload.AddSuffix(gtk.NewImageFromIconName("go-next-symbolic"))
+						upload.SetTitle("Upload file…")
+						upload.ConnectActivated(func() {
+							widget.ShowUploadDialog(ctx, e.Cluster, object, container.Name)
+						})
+						row.AddRow(upload)
+						e.ClusterPreferences.Sub(ctx, func(prefs api.ClusterPreferences) {
+							upload.SetVisible(!prefs.ReadOnly)
+						})
+
+						download := adw.NewActionRow()
+						download.SetActivatable(true)
+						download.AddSuffix(gtk.NewImageFromIconName("go-next-symbolic"))
+						download.SetTitle("Download file…")
+						download.ConnectActivated(func() {
+							widget.ShowDownloadDialog(ctx, e.Cluster, object, container.Name)
+						})
+						row.AddRow(download)
 					}
 				},
 			})
@@ -465,8 +514,23 @@ func (e *Core) CreateObjectProperties(ctx context.Context, _ *metav1.APIResource
 		props = append(props, &api.GroupProperty{Name: "Data", Children: data})
 	case *corev1.Service:
 		var ports []api.Property
+		var tcp []string
 		for _, p := range object.Spec.Ports {
-			ports = append(ports, &api.TextProperty{Name: p.Name, Value: strconv.Itoa(int(p.Port))})
+			if p.Protocol == corev1.ProtocolTCP {
+				tcp = append(tcp, strconv.Itoa(int(p.Port)))
+			}
+		}
+		for _, p := range object.Spec.Ports {
+			prop := &api.TextProperty{Name: p.Name, Value: strconv.Itoa(int(p.Port))}
+			if p.Protocol == corev1.ProtocolTCP && len(object.Spec.Selector) > 0 {
+				prop.Widget = func(w gtk.Widgetter, nv *adw.NavigationView) {
+					switch row := w.(type) {
+					case *adw.ActionRow:
+						row.AddSuffix(newPortForwardBox(ctx, e.Cluster, portForwardSpec(object), strconv.Itoa(int(p.Port)), tcp))
+					}
+				}
+			}
+			ports = append(ports, prop)
 		}
 		props = append(props, &api.GroupProperty{Name: "Service", Children: []api.Property{
 			&api.TextProperty{Name: "Cluster IP", Value: object.Spec.ClusterIP},
@@ -516,6 +580,22 @@ func (e *Core) CreateObjectProperties(ctx context.Context, _ *metav1.APIResource
 
 	case *corev1.Node:
 		infoProp := &api.GroupProperty{Name: "Info"}
+		if !style.Eq(style.Windows) {
+			infoProp.Widget = func(w gtk.Widgetter, nav *adw.NavigationView) {
+				if group, ok := w.(*adw.PreferencesGroup); ok {
+					button := gtk.NewButtonWithLabel("Shell")
+					button.AddCSSClass("flat")
+					button.SetTooltipText("Open a shell on the node using a privileged pod")
+					button.ConnectClicked(func() {
+						widget.OpenNodeShell(ctx, e.Cluster, object, nav)
+					})
+					group.SetHeaderSuffix(button)
+					e.ClusterPreferences.Sub(ctx, func(prefs api.ClusterPreferences) {
+						button.SetVisible(!prefs.ReadOnly)
+					})
+				}
+			}
+		}
 		mem := object.Status.Allocatable.Memory()
 		mem.RoundUp(resource.Mega)
 		mem.Format = resource.DecimalSI
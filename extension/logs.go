@@ -0,0 +1,41 @@
+package extension
+
+import (
+	"context"
+
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/getseabird/seabird/api"
+	"github.com/getseabird/seabird/widget"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// podLogsWidget adds a button to the pods group that opens the aggregated logs
+// of all pods matching the selector.
+func podLogsWidget(ctx context.Context, cluster *api.Cluster, object client.Object, selector *metav1.LabelSelector) func(gtk.Widgetter, *adw.NavigationView) {
+	return func(w gtk.Widgetter, nav *adw.NavigationView) {
+		group, ok := w.(*adw.PreferencesGroup)
+		if !ok {
+			return
+		}
+		sel, err := metav1.LabelSelectorAsSelector(selector)
+		if err != nil {
+			klog.Warningf("invalid selector on '%s': %s", object.GetName(), err)
+			return
+		}
+
+		button := gtk.NewButtonWithLabel("Logs")
+		button.AddCSSClass("flat")
+		button.SetTooltipText("Show logs of all pods")
+		button.ConnectClicked(func() {
+			nav.Push(widget.NewAggregatedLogPage(ctx, cluster, object.GetName(), func(pod *corev1.Pod) bool {
+				return pod.Namespace == object.GetNamespace() && sel.Matches(labels.Set(pod.Labels))
+			}).NavigationPage)
+		})
+		group.SetHeaderSuffix(button)
+	}
+}
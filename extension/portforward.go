@@ -2,112 +2,193 @@ package extension
 
 import (
 	"context"
-	"errors"
 	"fmt"
-	"net/http"
-	"os"
-	"time"
+	"maps"
+	"slices"
+	"strconv"
 
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
 	"github.com/diamondburned/gotk4/pkg/glib/v2"
 	"github.com/diamondburned/gotk4/pkg/gtk/v4"
 	"github.com/getseabird/seabird/api"
+	"github.com/getseabird/seabird/internal/ctxt"
 	"github.com/getseabird/seabird/widget"
-	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/apimachinery/pkg/util/httpstream"
-	"k8s.io/client-go/tools/portforward"
-	"k8s.io/client-go/transport/spdy"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-type PortForwarder struct {
-	*api.Cluster
-	forwarders map[types.NamespacedName]*portforward.PortForwarder
-}
-
-func (p *PortForwarder) New(ctx context.Context, name types.NamespacedName, ports []string) error {
-	readyChan := make(chan struct{}, 1)
-	errChan := make(chan error, 1)
+// newPortForwardBox has a button that toggles a forward of the remote port of
+// the target. Other ports of the target can be forwarded along with it. While
+// forwarded, a link opens the port in the browser. Forwards are kept in the
+// cluster's registry, so they outlive the object view.
+func newPortForwardBox(ctx context.Context, cluster *api.Cluster, spec api.PortForwardSpec, port string, ports []string) *gtk.Box {
+	box := gtk.NewBox(gtk.OrientationHorizontal, 4)
+	box.SetVAlign(gtk.AlignCenter)
+	var link *gtk.Button
+	btn := gtk.NewButton()
+	box.Append(btn)
 
-	url := p.Clientset.CoreV1().RESTClient().Post().Resource("pods").Namespace(name.Namespace).Name(name.Name).SubResource("portforward").URL()
-	transport, upgrader, err := spdy.RoundTripperFor(p.Config)
-	if err != nil {
-		return err
-	}
-
-	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, http.MethodPost, url)
-	tunnelingDialer, err := portforward.NewSPDYOverWebsocketDialer(url, p.Config)
-	if err != nil {
-		return err
-	}
-	dialer = portforward.NewFallbackDialer(tunnelingDialer, dialer, httpstream.IsUpgradeFailure)
+	update := func(forwards []api.PortForward) {
+		if link != nil {
+			box.Remove(link)
+			link = nil
+		}
+		fwd, ok := cluster.PortForwards.Find(spec.Namespace, spec.Target, port)
+		if !ok {
+			btn.SetChild(nil)
+			btn.SetIconName("vertical-arrows-long-symbolic")
+			btn.SetTooltipText("Forward port")
+			btn.AddCSSClass("flat")
+			return
+		}
+		content := gtk.NewBox(gtk.OrientationHorizontal, 2)
+		icon := gtk.NewImageFromIconName("cross-small-symbolic")
+		icon.AddCSSClass("error")
+		content.Append(icon)
+		for _, p := range fwd.Ports {
+			if local, remote := api.SplitPort(p); remote == port {
+				content.Append(gtk.NewLabel(local))
+			}
+		}
+		btn.SetChild(content)
+		btn.RemoveCSSClass("flat")
+		btn.SetTooltipText(fmt.Sprintf("Close forwarding port (%s)", fwd.Status))
 
-	forwarder, err := portforward.NewOnAddresses(dialer, []string{"localhost"}, ports, ctx.Done(), readyChan, nil, os.Stderr)
-	if err != nil {
-		return err
+		link = widget.NewPortForwardLink(ctx, fwd, port)
+		box.Prepend(link)
 	}
-	p.forwarders[name] = forwarder
+	cluster.PortForwards.Forwards.Sub(ctx, update)
 
-	go func() {
-		if err := forwarder.ForwardPorts(); err != nil {
-			errChan <- err
+	btn.ConnectClicked(func() {
+		if fwd, ok := cluster.PortForwards.Find(spec.Namespace, spec.Target, port); ok {
+			cluster.PortForwards.Stop(fwd.ID)
+			return
 		}
-	}()
+		showPortForwardDialog(ctx, cluster, spec, port, ports, btn)
+	})
 
-	select {
-	case <-readyChan:
-		return nil
-	case err := <-errChan:
-		return err
-	case <-time.After(5 * time.Second):
-		return errors.New("timeout")
-	}
+	return box
 }
 
-func (p *PortForwarder) GetPorts(name types.NamespacedName) ([]portforward.ForwardedPort, error) {
-	if forwarder := p.forwarders[name]; forwarder != nil {
-		return forwarder.GetPorts()
-	} else {
-		return nil, errors.New("not found")
+// showPortForwardDialog lets the user pick the bind address, and the local
+// port and HTTP path of each port to forward. Ports of the target that are
+// already forwarded aren't offered.
+func showPortForwardDialog(ctx context.Context, cluster *api.Cluster, spec api.PortForwardSpec, selected string, ports []string, btn *gtk.Button) {
+	group := adw.NewPreferencesGroup()
+	address := adw.NewEntryRow()
+	address.SetTitle("Bind address")
+	address.SetText("localhost")
+	group.Add(address)
+
+	type portRow struct {
+		port  string
+		check *gtk.CheckButton
+		local *adw.SpinRow
+		path  *adw.EntryRow
+	}
+	var rows []portRow
+	if !slices.Contains(ports, selected) {
+		ports = append([]string{selected}, ports...)
 	}
+	for _, port := range ports {
+		if _, ok := cluster.PortForwards.Find(spec.Namespace, spec.Target, port); ok && port != selected {
+			continue
+		}
+		row := portRow{port: port, check: gtk.NewCheckButton(), local: adw.NewSpinRowWithRange(0, 65535, 1), path: adw.NewEntryRow()}
+		row.check.SetActive(port == selected)
+		row.local.AddPrefix(row.check)
+		row.local.SetTitle(fmt.Sprintf("Port %s", port))
+		row.local.SetSubtitle("Local port, 0 picks a free one")
+		if p, err := strconv.Atoi(port); err == nil && p >= 1024 {
+			row.local.SetValue(float64(p))
+		}
+		group.Add(row.local)
+		row.path.SetTitle(fmt.Sprintf("HTTP path of port %s, e.g. /metrics", port))
+		row.path.SetText(spec.Paths[port])
+		row.path.SetVisible(row.check.Active())
+		row.check.ConnectToggled(func() {
+			row.path.SetVisible(row.check.Active())
+		})
+		group.Add(row.path)
+		rows = append(rows, row)
+	}
+
+	dialog := adw.NewMessageDialog(ctxt.MustFrom[*gtk.Window](ctx), "Port Forward", fmt.Sprintf("Forward ports of %s", spec.Target))
+	dialog.SetExtraChild(group)
+	dialog.AddResponse("cancel", "Cancel")
+	dialog.AddResponse("forward", "Forward")
+	dialog.SetResponseAppearance("forward", adw.ResponseSuggested)
+	dialog.SetCloseResponse("cancel")
+	dialog.ConnectResponse(func(response string) {
+		if response != "forward" {
+			return
+		}
+		spec := spec
+		spec.Address = address.Text()
+		spec.Paths = maps.Clone(spec.Paths)
+		for _, row := range rows {
+			if !row.check.Active() {
+				continue
+			}
+			local := ""
+			if v := int(row.local.Value()); v > 0 {
+				local = strconv.Itoa(v)
+			}
+			spec.Ports = append(spec.Ports, fmt.Sprintf("%s:%s", local, row.port))
+			if path := row.path.Text(); path != "" {
+				if spec.Paths == nil {
+					spec.Paths = map[string]string{}
+				}
+				spec.Paths[row.port] = path
+			}
+		}
+		if len(spec.Ports) == 0 {
+			return
+		}
+		btn.SetSensitive(false)
+		go func() {
+			_, err := cluster.PortForwards.Start(spec)
+			glib.IdleAdd(func() {
+				btn.SetSensitive(true)
+				if err != nil {
+					widget.ShowErrorDialog(ctx, "Port forward error", err)
+				}
+			})
+		}()
+	})
+	dialog.Present()
 }
 
-func (p *PortForwarder) Close(name types.NamespacedName) error {
-	if forwarder := p.forwarders[name]; forwarder != nil {
-		forwarder.Close()
-		delete(p.forwarders, name)
-		return nil
-	} else {
-		return errors.New("not found")
+// portForwardSpec returns the forward target of pods, services and workloads.
+func portForwardSpec(object client.Object) api.PortForwardSpec {
+	spec := api.PortForwardSpec{Namespace: object.GetNamespace()}
+	switch object := object.(type) {
+	case *corev1.Pod:
+		spec.Target = "pod/" + object.Name
+		spec.Pod = object.Name
+		spec.Selector = api.PodSelector(object)
+	case *corev1.Service:
+		spec.Target = "service/" + object.Name
+	case *appsv1.Deployment:
+		spec.Target = "deployment/" + object.Name
+		spec.Selector = object.Spec.Selector.MatchLabels
+	case *appsv1.StatefulSet:
+		spec.Target = "statefulset/" + object.Name
+		spec.Selector = object.Spec.Selector.MatchLabels
 	}
+	return spec
 }
 
-func (p *PortForwarder) UpdateButton(ctx context.Context, btn *gtk.Button, name types.NamespacedName, ports []string) {
-	var handle glib.SignalHandle
-	if fwd, err := p.GetPorts(name); err != nil {
-		btn.SetIconName("vertical-arrows-long-symbolic")
-		btn.SetTooltipText("Forward port to localhost")
-		btn.AddCSSClass("flat")
-		handle = btn.ConnectClicked(func() {
-			if err := p.New(ctx, name, ports); err != nil {
-				widget.ShowErrorDialog(ctx, "Port forward error", err)
-			} else {
-				p.UpdateButton(ctx, btn, name, ports)
+// tcpPorts returns the TCP container ports of the pod spec.
+func tcpPorts(spec corev1.PodSpec) []string {
+	var ports []string
+	for _, container := range spec.Containers {
+		for _, port := range container.Ports {
+			if port.Protocol == "" || port.Protocol == corev1.ProtocolTCP {
+				ports = append(ports, strconv.Itoa(int(port.ContainerPort)))
 			}
-			btn.HandlerDisconnect(handle)
-		})
-	} else {
-		box := gtk.NewBox(gtk.OrientationHorizontal, 2)
-		icon := gtk.NewImageFromIconName("cross-small-symbolic")
-		icon.AddCSSClass("error")
-		box.Append(icon)
-		box.Append(gtk.NewLabel(fmt.Sprintf("%d", fwd[0].Local)))
-		btn.SetChild(box)
-		btn.RemoveCSSClass("flat")
-		btn.SetTooltipText("Close forwarding port")
-		handle = btn.ConnectClicked(func() {
-			p.Close(name)
-			p.UpdateButton(ctx, btn, name, ports)
-			btn.HandlerDisconnect(handle)
-		})
+		}
 	}
-
+	return ports
 }
@@ -43,6 +43,7 @@ func NewApplication(version string) (*Application, error) {
 	state.Preferences.Sub(ctx, func(p api.Preferences) {
 		adw.StyleManagerGetDefault().SetColorScheme(adw.ColorScheme(p.ColorScheme))
 	})
+	state.WatchKubeconfig(ctx)
 
 	style.Load()
 
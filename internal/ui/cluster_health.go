@@ -0,0 +1,141 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/getseabird/seabird/api"
+	"github.com/getseabird/seabird/internal/ui/common"
+)
+
+// ClusterHealthWindow shows the connection report of the cluster: API groups
+// that failed discovery, disabled subsystems and failing informers.
+type ClusterHealthWindow struct {
+	*adw.PreferencesWindow
+	*common.ClusterState
+	page   *adw.PreferencesPage
+	groups []*adw.PreferencesGroup
+}
+
+func NewClusterHealthWindow(ctx context.Context, state *common.ClusterState) *ClusterHealthWindow {
+	w := ClusterHealthWindow{
+		PreferencesWindow: adw.NewPreferencesWindow(),
+		ClusterState:      state,
+		page:              adw.NewPreferencesPage(),
+	}
+	w.SetTitle("Cluster Health")
+	w.SetSearchEnabled(false)
+	w.Add(w.page)
+
+	ctx, cancel := context.WithCancel(ctx)
+	w.ConnectCloseRequest(func() bool {
+		cancel()
+		return false
+	})
+	w.Report.Sub(ctx, w.update)
+
+	return &w
+}
+
+func (w *ClusterHealthWindow) update(report api.ConnectionReport) {
+	for _, group := range w.groups {
+		w.page.Remove(group)
+	}
+	w.groups = nil
+
+	var groups []healthRow
+	for gv, err := range report.FailedGroups {
+		groups = append(groups, healthRow{gv.String(), err.Error()})
+	}
+	w.addGroup("API Groups", "Resources of these groups are missing because discovery failed", "All API groups were discovered", groups)
+
+	var disabled []healthRow
+	for name, err := range report.Disabled {
+		disabled = append(disabled, healthRow{name, err.Error()})
+	}
+	w.addGroup("Disabled", "", "All features are available", disabled)
+
+	var informers []healthRow
+	for gvr, failure := range report.Informers {
+		informers = append(informers, healthRow{
+			gvr.GroupResource().String(),
+			fmt.Sprintf("%d failures, last at %s: %s", failure.Failures, failure.Time.Format("15:04:05"), failure.Err),
+		})
+	}
+	w.addGroup("Watches", "Objects of these resources may be stale because their watches keep failing", "All watches are working", informers)
+}
+
+type healthRow struct {
+	title    string
+	subtitle string
+}
+
+func (w *ClusterHealthWindow) addGroup(title, description, healthy string, rows []healthRow) {
+	group := adw.NewPreferencesGroup()
+	group.SetTitle(title)
+	group.SetDescription(description)
+	w.page.Add(group)
+	w.groups = append(w.groups, group)
+
+	if len(rows) == 0 {
+		row := adw.NewActionRow()
+		row.SetTitle(healthy)
+		icon := gtk.NewImageFromIconName("object-select-symbolic")
+		icon.AddCSSClass("success")
+		row.AddPrefix(icon)
+		group.Add(row)
+		return
+	}
+
+	slices.SortFunc(rows, func(a, b healthRow) int {
+		return strings.Compare(a.title, b.title)
+	})
+	for _, r := range rows {
+		row := adw.NewActionRow()
+		row.SetTitle(r.title)
+		row.SetSubtitle(r.subtitle)
+		row.SetSubtitleSelectable(true)
+		icon := gtk.NewImageFromIconName("dialog-warning-symbolic")
+		icon.AddCSSClass("warning")
+		row.AddPrefix(icon)
+		group.Add(row)
+	}
+}
+
+// watchReport shows a toast when problems are added to the connection
+// report, with a button to show the details.
+func (w *ClusterWindow) watchReport() {
+	seen := map[string]bool{}
+	w.Report.Sub(w.ctx, func(report api.ConnectionReport) {
+		var added int
+		keys := map[string]bool{}
+		for gv := range report.FailedGroups {
+			keys["group/"+gv.String()] = true
+		}
+		for gvr := range report.Informers {
+			keys["informer/"+gvr.String()] = true
+		}
+		for key := range keys {
+			if !seen[key] {
+				added++
+			}
+		}
+		seen = keys
+		if added == 0 {
+			return
+		}
+
+		toast := adw.NewToast(fmt.Sprintf("Cluster has %d problems, some resources may be missing or stale", report.Problems()))
+		if report.Problems() == 1 {
+			toast.SetTitle("Cluster has a problem, some resources may be missing or stale")
+		}
+		toast.SetButtonLabel("Details")
+		toast.SetActionName("win.health")
+		toast.SetTimeout(10)
+		w.toastOverlay.AddToast(toast)
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
 	"github.com/diamondburned/gotk4/pkg/glib/v2"
@@ -32,8 +33,19 @@ type ClusterPrefPage struct {
 	bearer     *adw.EntryRow
 	exec       *adw.ActionRow
 	readonly   *adw.SwitchRow
+	namespaces *adw.EntryRow
 	insecure   *adw.SwitchRow
 	execDelete *gtk.Button
+	proxy      *adw.EntryRow
+	noProxy    *adw.SwitchRow
+	serverName *adw.EntryRow
+	shells     *adw.EntryRow
+	debugImage *adw.EntryRow
+	nodeImage  *adw.EntryRow
+	nodeNs     *adw.EntryRow
+	record     *adw.SwitchRow
+	recordDir  *adw.EntryRow
+	imperson   *impersonationRows
 	actions    *adw.Bin
 }
 
@@ -79,6 +91,10 @@ func (p *ClusterPrefPage) createContent() *adw.PreferencesPage {
 	p.readonly.SetTitle("Read-only")
 	general.Add(p.readonly)
 
+	p.namespaces = adw.NewEntryRow()
+	p.namespaces.SetTitle("Namespaces (comma-separated, empty to detect)")
+	general.Add(p.namespaces)
+
 	p.insecure = adw.NewSwitchRow()
 	p.insecure.SetTitle("Skip TLS Verification")
 	general.Add(p.insecure)
@@ -111,6 +127,51 @@ func (p *ClusterPrefPage) createContent() *adw.PreferencesPage {
 	p.exec.AddSuffix(p.execDelete)
 	auth.AddRow(p.exec)
 
+	network := adw.NewExpanderRow()
+	general.Add(network)
+	network.SetTitle("Network")
+	p.proxy = adw.NewEntryRow()
+	p.proxy.SetTitle("Proxy URL (http, https or socks5)")
+	network.AddRow(p.proxy)
+	p.noProxy = adw.NewSwitchRow()
+	p.noProxy.SetTitle("Ignore proxy environment")
+	p.noProxy.SetSubtitle("Connect directly if no proxy URL is set")
+	network.AddRow(p.noProxy)
+	p.serverName = adw.NewEntryRow()
+	p.serverName.SetTitle("TLS server name")
+	network.AddRow(p.serverName)
+
+	terminal := adw.NewExpanderRow()
+	general.Add(terminal)
+	terminal.SetTitle("Terminal")
+	p.shells = adw.NewEntryRow()
+	p.shells.SetTitle("Shells (tried in order, comma-separated)")
+	terminal.AddRow(p.shells)
+	p.debugImage = adw.NewEntryRow()
+	p.debugImage.SetTitle("Debug container image")
+	terminal.AddRow(p.debugImage)
+	p.nodeImage = adw.NewEntryRow()
+	p.nodeImage.SetTitle("Node shell image")
+	terminal.AddRow(p.nodeImage)
+	p.nodeNs = adw.NewEntryRow()
+	p.nodeNs.SetTitle("Node shell namespace")
+	terminal.AddRow(p.nodeNs)
+	p.record = adw.NewSwitchRow()
+	p.record.SetTitle("Record sessions")
+	p.record.SetSubtitle("Save terminal sessions as asciicast files")
+	terminal.AddRow(p.record)
+	p.recordDir = adw.NewEntryRow()
+	p.recordDir.SetTitle("Recording directory")
+	terminal.AddRow(p.recordDir)
+
+	impersonate := adw.NewExpanderRow()
+	general.Add(impersonate)
+	impersonate.SetTitle("Impersonation")
+	p.imperson = newImpersonationRows()
+	for _, row := range p.imperson.rows() {
+		impersonate.AddRow(row)
+	}
+
 	p.updateValues(p.prefs.Value())
 
 	p.actions = adw.NewBin()
@@ -133,15 +194,36 @@ func (p *ClusterPrefPage) createSaveButton() *gtk.Button {
 		cluster.Name = p.name.Text()
 		cluster.Host = p.host.Text()
 		cluster.ReadOnly = p.readonly.Active()
+		cluster.Namespaces = nil
+		for _, ns := range strings.Split(p.namespaces.Text(), ",") {
+			if ns = strings.TrimSpace(ns); ns != "" {
+				cluster.Namespaces = append(cluster.Namespaces, ns)
+			}
+		}
 		cluster.SkipTlsVerification = p.insecure.Active()
 		cluster.TLS.Insecure = p.insecure.Active()
 		cluster.TLS.CertData = []byte(p.cert.Text())
 		cluster.TLS.KeyData = []byte(p.key.Text())
 		cluster.TLS.CAData = []byte(p.ca.Text())
 		cluster.BearerToken = p.bearer.Text()
+		cluster.ProxyURL = strings.TrimSpace(p.proxy.Text())
+		cluster.NoProxy = p.noProxy.Active()
+		cluster.TLS.ServerName = strings.TrimSpace(p.serverName.Text())
 		if p.exec.Subtitle() == "" {
 			cluster.Exec = nil
 		}
+		cluster.Terminal.Shells = nil
+		for _, shell := range strings.Split(p.shells.Text(), ",") {
+			if shell = strings.TrimSpace(shell); shell != "" {
+				cluster.Terminal.Shells = append(cluster.Terminal.Shells, shell)
+			}
+		}
+		cluster.Terminal.DebugImage = strings.TrimSpace(p.debugImage.Text())
+		cluster.Terminal.NodeShellImage = strings.TrimSpace(p.nodeImage.Text())
+		cluster.Terminal.NodeShellNamespace = strings.TrimSpace(p.nodeNs.Text())
+		cluster.Terminal.Record = p.record.Active()
+		cluster.Terminal.RecordDirectory = strings.TrimSpace(p.recordDir.Text())
+		cluster.Impersonate = p.imperson.get()
 		cluster.Defaults()
 
 		if showClusterPrefsErrorDialog(p.ctx, cluster) {
@@ -150,7 +232,10 @@ func (p *ClusterPrefPage) createSaveButton() *gtk.Button {
 		}
 
 		go func() {
-			_, err := p.NewClusterState(p.ctx, pubsub.NewProperty(cluster))
+			state, err := p.NewClusterState(p.ctx, pubsub.NewProperty(cluster))
+			if err == nil {
+				state.Disconnect()
+			}
 			glib.IdleAdd(func() {
 				defer spinner.Stop()
 				if err != nil {
@@ -288,11 +373,26 @@ func (p *ClusterPrefPage) updateValues(prefs api.ClusterPreferences) {
 	p.name.SetText(prefs.Name)
 	p.host.SetText(prefs.Host)
 	p.readonly.SetActive(prefs.ReadOnly)
+	p.namespaces.SetText(strings.Join(prefs.Namespaces, ", "))
 	p.insecure.SetActive(prefs.SkipTlsVerification)
 	p.cert.SetText(string(prefs.TLS.CertData))
 	p.key.SetText(string(prefs.TLS.KeyData))
 	p.ca.SetText(string(prefs.TLS.CAData))
 	p.bearer.SetText(string(prefs.BearerToken))
+	p.proxy.SetText(prefs.ProxyURL)
+	p.noProxy.SetActive(prefs.NoProxy)
+	p.serverName.SetText(prefs.TLS.ServerName)
+	p.shells.SetText(strings.Join(prefs.Terminal.Shells, ", "))
+	p.debugImage.SetText(prefs.Terminal.DebugImage)
+	p.nodeImage.SetText(prefs.Terminal.NodeShellImage)
+	p.nodeNs.SetText(prefs.Terminal.NodeShellNamespace)
+	p.record.SetActive(prefs.Terminal.Record)
+	if prefs.Terminal.RecordDirectory != "" {
+		p.recordDir.SetText(prefs.Terminal.RecordDirectory)
+	} else {
+		p.recordDir.SetText(widget.DefaultRecordDirectory())
+	}
+	p.imperson.set(prefs.Impersonate)
 	if prefs.Exec != nil {
 		p.exec.SetSubtitle(prefs.Exec.Command)
 		p.execDelete.SetSensitive(true)
@@ -309,5 +409,7 @@ func (p *ClusterPrefPage) updateValues(prefs api.ClusterPreferences) {
 		p.ca.SetSensitive(false)
 		p.bearer.SetSensitive(false)
 		p.execDelete.SetSensitive(false)
+		p.proxy.SetSensitive(false)
+		p.serverName.SetSensitive(false)
 	}
 }
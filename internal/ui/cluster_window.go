@@ -43,7 +43,11 @@ func NewClusterWindow(ctx context.Context, app *gtk.Application, state *common.C
 		cancel:            cancel,
 	}
 	w.SetIconName("seabird")
-	w.SetTitle(fmt.Sprintf("%s - %s", w.ClusterPreferences.Value().Name, ApplicationName))
+	if as := impersonationLabel(w.ClusterPreferences.Value()); as != "" {
+		w.SetTitle(fmt.Sprintf("%s (as %s) - %s", w.ClusterPreferences.Value().Name, as, ApplicationName))
+	} else {
+		w.SetTitle(fmt.Sprintf("%s - %s", w.ClusterPreferences.Value().Name, ApplicationName))
+	}
 	w.SetDefaultSize(1000, 600)
 
 	var h glib.SignalHandle
@@ -57,6 +61,9 @@ func NewClusterWindow(ctx context.Context, app *gtk.Application, state *common.C
 			w.HandlerDisconnect(h)
 			return true
 		}
+		w.PortForwards.StopAll()
+		w.Disconnect()
+		w.cancel()
 		return false
 	})
 
@@ -73,7 +80,11 @@ func NewClusterWindow(ctx context.Context, app *gtk.Application, state *common.C
 	paned.SetPosition(225)
 	paned.SetShrinkStartChild(false)
 	paned.SetShrinkEndChild(false)
-	w.toastOverlay.SetChild(paned)
+	paned.SetVExpand(true)
+	content := gtk.NewBox(gtk.OrientationVertical, 0)
+	content.Append(w.newConnectionBanner())
+	content.Append(paned)
+	w.toastOverlay.SetChild(content)
 
 	w.dialog = adw.NewDialog()
 	w.dialog.SetPresentationMode(adw.DialogBottomSheet)
@@ -121,6 +132,8 @@ func NewClusterWindow(ctx context.Context, app *gtk.Application, state *common.C
 	paned.SetEndChild(viewStack)
 
 	w.createActions()
+	w.watchReport()
+	restorePortForwards(ctx, w.Cluster)
 	return &w
 }
 
@@ -147,6 +160,20 @@ func (w *ClusterWindow) createActions() {
 	w.AddAction(disconnect)
 	w.Application().SetAccelsForAction("win.disconnect", []string{"<Ctrl>Q"})
 
+	impersonate := gio.NewSimpleAction("impersonate", nil)
+	impersonate.ConnectActivate(func(_ *glib.Variant) {
+		w.showImpersonateDialog()
+	})
+	w.AddAction(impersonate)
+
+	health := gio.NewSimpleAction("health", nil)
+	health.ConnectActivate(func(_ *glib.Variant) {
+		health := NewClusterHealthWindow(w.ctx, w.ClusterState)
+		health.SetTransientFor(&w.Window)
+		health.Present()
+	})
+	w.AddAction(health)
+
 	action := gio.NewSimpleAction("prefs", nil)
 	action.ConnectActivate(func(_ *glib.Variant) {
 		prefs := NewPreferencesWindow(w.ctx, w.State)
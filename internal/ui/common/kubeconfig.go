@@ -0,0 +1,55 @@
+package common
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/diamondburned/gotk4/pkg/gio/v2"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/getseabird/seabird/api"
+	"github.com/zmwangx/debounce"
+	"k8s.io/klog/v2"
+)
+
+// WatchKubeconfig reloads clusters when their kubeconfig files change. Tools
+// that rotate credentials often write several times in a row, so reloads are
+// debounced.
+func (s *State) WatchKubeconfig(ctx context.Context) {
+	paths := api.KubeconfigPaths()
+	for _, cluster := range s.Preferences.Value().Clusters {
+		if kubeconfig := cluster.Value().Kubeconfig; kubeconfig != nil && !slices.Contains(paths, kubeconfig.Path) {
+			paths = append(paths, kubeconfig.Path)
+		}
+	}
+
+	for _, path := range paths {
+		monitor, err := gio.NewFileForPath(path).MonitorFile(ctx, gio.FileMonitorWatchMoves)
+		if err != nil {
+			klog.Infof("watching %s: %s", path, err)
+			continue
+		}
+		reload, _ := debounce.Debounce(func() {
+			glib.IdleAdd(func() {
+				s.ReloadKubeconfig(path)
+			})
+		}, time.Second)
+		m := gio.BaseFileMonitor(monitor)
+		m.ConnectChanged(func(_, _ gio.Filer, event gio.FileMonitorEvent) {
+			switch event {
+			case gio.FileMonitorEventChanged, gio.FileMonitorEventCreated, gio.FileMonitorEventMovedIn, gio.FileMonitorEventRenamed:
+				reload()
+			}
+		})
+		s.monitors = append(s.monitors, m)
+	}
+}
+
+// ReloadKubeconfig refreshes the clusters of the kubeconfig file and
+// publishes the preferences if contexts were added.
+func (s *State) ReloadKubeconfig(path string) {
+	prefs := s.Preferences.Value()
+	if prefs.ReloadKubeconfig(path) {
+		s.Preferences.Pub(prefs)
+	}
+}
@@ -3,6 +3,7 @@ package common
 import (
 	"context"
 
+	"github.com/diamondburned/gotk4/pkg/gio/v2"
 	"github.com/getseabird/seabird/api"
 	"github.com/getseabird/seabird/extension"
 	"github.com/getseabird/seabird/internal/ctxt"
@@ -18,6 +19,7 @@ import (
 
 type State struct {
 	Preferences pubsub.Property[api.Preferences]
+	monitors    []*gio.FileMonitor
 }
 
 type ClusterState struct {
@@ -64,14 +66,22 @@ func (s *State) NewClusterState(ctx context.Context, clusterPrefs pubsub.Propert
 		Objects:          pubsub.NewProperty[[]client.Object](nil),
 	}
 
-	if err := api.InformerConnectProperty(ctx, cluster, schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}, state.Namespaces); err != nil {
+	if len(cluster.Namespaces) > 0 {
+		// Restricted users usually can't list namespaces
+		var namespaces []*corev1.Namespace
+		for _, name := range cluster.Namespaces {
+			namespaces = append(namespaces, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}})
+		}
+		state.Namespaces.Pub(namespaces)
+	} else if err := api.InformerConnectProperty(ctx, cluster, schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}, state.Namespaces); err != nil {
 		klog.Errorf("watching namespaces: %v", err)
 	}
 
 	for _, new := range extension.Extensions {
 		ext, err := new(ctx, cluster)
 		if err != nil {
-			klog.Error("failed to load extension: %s", err)
+			klog.Errorf("failed to load extension: %s", err)
+			continue
 		}
 		state.Extensions = append(state.Extensions, ext)
 	}
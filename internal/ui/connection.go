@@ -0,0 +1,56 @@
+package ui
+
+import (
+	"fmt"
+
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/getseabird/seabird/api"
+)
+
+// newConnectionBanner is revealed while the cluster is degraded, offline or
+// rejects the credentials, with the time the cluster last responded.
+func (w *ClusterWindow) newConnectionBanner() *adw.Banner {
+	banner := adw.NewBanner("")
+	banner.SetUseMarkup(false)
+	banner.SetButtonLabel("Details")
+	banner.ConnectButtonClicked(func() {
+		w.ActivateAction("health", nil)
+	})
+
+	previous := w.Connection.Value().State
+	w.Connection.Sub(w.ctx, func(conn api.Connection) {
+		lastSync := conn.LastSync.Format("15:04:05")
+		switch conn.State {
+		case api.ConnectionOffline:
+			banner.SetTitle(fmt.Sprintf("Cluster is unreachable, data is from %s", lastSync))
+		case api.ConnectionDegraded:
+			banner.SetTitle(fmt.Sprintf("Cluster is degraded: %s", conn.Err))
+		case api.ConnectionUnauthorized:
+			banner.SetTitle(fmt.Sprintf("Cluster rejected the credentials: %s", conn.Err))
+		}
+		banner.SetRevealed(conn.State != api.ConnectionConnected)
+
+		if conn.State == api.ConnectionUnauthorized && previous != api.ConnectionUnauthorized {
+			w.refreshCredentials()
+		}
+		if (previous == api.ConnectionOffline || previous == api.ConnectionUnauthorized) &&
+			conn.State != api.ConnectionOffline && conn.State != api.ConnectionUnauthorized {
+			if previous == api.ConnectionOffline {
+				w.refreshCredentials()
+			}
+			w.toastOverlay.AddToast(adw.NewToast("Reconnected to cluster"))
+		}
+		previous = conn.State
+	})
+
+	return banner
+}
+
+// refreshCredentials reloads the kubeconfig of the cluster, as tools may have
+// rotated tokens while the connection was down or after they were rejected.
+// Exec plugins are run again by the cluster itself.
+func (w *ClusterWindow) refreshCredentials() {
+	if kubeconfig := w.ClusterPreferences.Value().Kubeconfig; kubeconfig != nil {
+		w.State.ReloadKubeconfig(kubeconfig.Path)
+	}
+}
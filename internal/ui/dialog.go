@@ -19,6 +19,16 @@ func showClusterPrefsErrorDialog(ctx context.Context, prefs api.ClusterPreferenc
 		return true
 	}
 
+	if _, err := prefs.Proxy(); err != nil {
+		widget.ShowErrorDialog(ctx, "Invalid proxy URL", err)
+		return true
+	}
+
+	if imp := prefs.Impersonate; imp.UserName == "" && (len(imp.Groups) > 0 || len(imp.Extra) > 0 || imp.UID != "") {
+		widget.ShowErrorDialog(ctx, "Error", errors.New("impersonating groups, UID or extra fields requires a user"))
+		return true
+	}
+
 	if ex := prefs.Exec; ex != nil {
 		if _, err := exec.LookPath(ex.Command); err != nil {
 			w, _ := ctxt.From[*gtk.Window](ctx)
@@ -0,0 +1,157 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/getseabird/seabird/api"
+	"github.com/getseabird/seabird/internal/pubsub"
+	"github.com/getseabird/seabird/widget"
+	"k8s.io/client-go/rest"
+)
+
+// impersonationRows edits impersonation settings. Groups are comma-separated,
+// extra fields are comma-separated key=value pairs.
+type impersonationRows struct {
+	user   *adw.EntryRow
+	uid    *adw.EntryRow
+	groups *adw.EntryRow
+	extra  *adw.EntryRow
+}
+
+func newImpersonationRows() *impersonationRows {
+	r := &impersonationRows{
+		user:   adw.NewEntryRow(),
+		uid:    adw.NewEntryRow(),
+		groups: adw.NewEntryRow(),
+		extra:  adw.NewEntryRow(),
+	}
+	r.user.SetTitle("User, e.g. system:serviceaccount:ns:name")
+	r.uid.SetTitle("UID")
+	r.groups.SetTitle("Groups (comma-separated)")
+	r.extra.SetTitle("Extra (comma-separated key=value)")
+	return r
+}
+
+func (r *impersonationRows) rows() []*adw.EntryRow {
+	return []*adw.EntryRow{r.user, r.uid, r.groups, r.extra}
+}
+
+func (r *impersonationRows) set(config rest.ImpersonationConfig) {
+	r.user.SetText(config.UserName)
+	r.uid.SetText(config.UID)
+	r.groups.SetText(strings.Join(config.Groups, ", "))
+	var extra []string
+	for key, values := range config.Extra {
+		for _, value := range values {
+			extra = append(extra, fmt.Sprintf("%s=%s", key, value))
+		}
+	}
+	r.extra.SetText(strings.Join(extra, ", "))
+}
+
+func (r *impersonationRows) get() rest.ImpersonationConfig {
+	config := rest.ImpersonationConfig{
+		UserName: strings.TrimSpace(r.user.Text()),
+		UID:      strings.TrimSpace(r.uid.Text()),
+	}
+	for _, group := range strings.Split(r.groups.Text(), ",") {
+		if group = strings.TrimSpace(group); group != "" {
+			config.Groups = append(config.Groups, group)
+		}
+	}
+	for _, pair := range strings.Split(r.extra.Text(), ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || key == "" {
+			continue
+		}
+		if config.Extra == nil {
+			config.Extra = map[string][]string{}
+		}
+		config.Extra[key] = append(config.Extra[key], value)
+	}
+	return config
+}
+
+// showImpersonateDialog switches the user the cluster is viewed as. The
+// window reconnects, as clients are created with the impersonation config.
+func (w *ClusterWindow) showImpersonateDialog() {
+	rows := newImpersonationRows()
+	rows.set(w.ClusterPreferences.Value().Impersonate)
+	group := adw.NewPreferencesGroup()
+	for _, row := range rows.rows() {
+		group.Add(row)
+	}
+
+	dialog := adw.NewMessageDialog(&w.Window, "View as…", "Requests are made as this user, to see what it can access")
+	dialog.SetExtraChild(group)
+	dialog.AddResponse("cancel", "Cancel")
+	if prefs := w.ClusterPreferences.Value(); prefs.Impersonating() {
+		dialog.AddResponse("reset", "Stop Impersonating")
+		dialog.SetResponseAppearance("reset", adw.ResponseDestructive)
+	}
+	dialog.AddResponse("apply", "View As")
+	dialog.SetResponseAppearance("apply", adw.ResponseSuggested)
+	dialog.SetCloseResponse("cancel")
+	dialog.ConnectResponse(func(response string) {
+		prefs := w.ClusterPreferences.Value()
+		switch response {
+		case "apply":
+			prefs.Impersonate = rows.get()
+		case "reset":
+			prefs.Impersonate = rest.ImpersonationConfig{}
+		default:
+			return
+		}
+		if showClusterPrefsErrorDialog(w.ctx, prefs) {
+			return
+		}
+		w.reconnect(prefs)
+	})
+	dialog.Present()
+}
+
+// reconnect connects with the changed preferences and replaces the window.
+// The shared preferences are only changed once the connection succeeds, so
+// the current window keeps working meanwhile.
+func (w *ClusterWindow) reconnect(prefs api.ClusterPreferences) {
+	w.toastOverlay.AddToast(adw.NewToast("Reconnecting…"))
+	go func() {
+		// Outlives this window, but ends with the new one
+		ctx, cancel := context.WithCancel(context.WithoutCancel(w.ctx))
+		state, err := w.State.NewClusterState(ctx, pubsub.NewProperty(prefs))
+		glib.IdleAdd(func() {
+			if err != nil {
+				cancel()
+				widget.ShowErrorDialog(w.ctx, "Cluster connection failed", err)
+				return
+			}
+			// The new cluster follows the shared preferences from now on
+			connected := state.ClusterPreferences
+			w.ClusterPreferences.Pub(prefs)
+			w.ClusterPreferences.Sub(ctx, connected.Pub)
+			state.ClusterPreferences = w.ClusterPreferences
+
+			app := w.Application()
+			w.cancel()
+			w.Close()
+			window := NewClusterWindow(ctx, app, state)
+			context.AfterFunc(window.ctx, cancel)
+			window.Present()
+		})
+	}()
+}
+
+// impersonationLabel describes who the cluster is viewed as.
+func impersonationLabel(prefs api.ClusterPreferences) string {
+	if !prefs.Impersonating() {
+		return ""
+	}
+	if prefs.Impersonate.UserName != "" {
+		return prefs.Impersonate.UserName
+	}
+	return strings.Join(prefs.Impersonate.Groups, ", ")
+}
@@ -72,6 +72,11 @@ func NewList(ctx context.Context, state *common.ClusterState, dialog *adw.Dialog
 	l.SelectedResource.Sub(ctx, l.onSelectedResourceChange)
 	l.Objects.Sub(ctx, l.onObjectsChange)
 	l.SearchFilter.Sub(ctx, l.onSearchFilterChange)
+	l.Resources.Sub(ctx, func(_ []metav1.APIResource) {
+		// Printer columns may have changed with the resources
+		l.columnType = nil
+		l.onObjectsChange(l.Objects.Value())
+	})
 
 	filterNamespace := gio.NewSimpleAction("filterNamespace", glib.NewVariantType("s"))
 	filterNamespace.ConnectActivate(func(parameter *glib.Variant) {
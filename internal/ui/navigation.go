@@ -31,7 +31,6 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime/schema"
-	"k8s.io/client-go/informers"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/reference"
 	"k8s.io/klog/v2"
@@ -41,31 +40,33 @@ import (
 type Navigation struct {
 	*adw.ToolbarView
 	*common.ClusterState
-	ctx                   context.Context
-	resourceList          *gtk.ListBox
-	pinList               *gtk.ListBox
-	pinRows               []*gtk.ListBoxRow
-	pinViews              []*adw.NavigationView
-	favourites            []*gtk.ListBoxRow
-	resources             []*gtk.ListBoxRow
-	viewStack             *gtk.Stack
-	editor                *editor.EditorWindow
-	resourcesToggle       *gtk.ToggleButton
-	pinsToggle            *gtk.ToggleButton
-	search                *gtk.SearchEntry
-	cancelFuncs           map[string]context.CancelFunc
-	informerRegistrations map[informers.GenericInformer]cache.ResourceEventHandlerRegistration
+	ctx             context.Context
+	resourceList    *gtk.ListBox
+	pinList         *gtk.ListBox
+	pinRows         []*gtk.ListBoxRow
+	pinViews        []*adw.NavigationView
+	favourites      []*gtk.ListBoxRow
+	resources       []*gtk.ListBoxRow
+	viewStack       *gtk.Stack
+	editor          *editor.EditorWindow
+	resourcesToggle *gtk.ToggleButton
+	pinsToggle      *gtk.ToggleButton
+	search          *gtk.SearchEntry
+	cancelFuncs     map[string]context.CancelFunc
+	// statusCtx is canceled when the resource list is recreated, removing the
+	// status count handlers of its favourites.
+	statusCtx    context.Context
+	cancelStatus context.CancelFunc
 }
 
 func NewNavigation(ctx context.Context, state *common.ClusterState, viewStack *gtk.Stack, editor *editor.EditorWindow) *Navigation {
 	n := &Navigation{
-		ToolbarView:           adw.NewToolbarView(),
-		ctx:                   ctx,
-		ClusterState:          state,
-		viewStack:             viewStack,
-		editor:                editor,
-		cancelFuncs:           map[string]context.CancelFunc{},
-		informerRegistrations: map[informers.GenericInformer]cache.ResourceEventHandlerRegistration{},
+		ToolbarView:  adw.NewToolbarView(),
+		ctx:          ctx,
+		ClusterState: state,
+		viewStack:    viewStack,
+		editor:       editor,
+		cancelFuncs:  map[string]context.CancelFunc{},
 	}
 	n.SetVExpand(true)
 	n.AddCSSClass("navigation-sidebar")
@@ -74,6 +75,10 @@ func NewNavigation(ctx context.Context, state *common.ClusterState, viewStack *g
 	title := gtk.NewLabel(n.ClusterPreferences.Value().Name)
 	title.SetEllipsize(pango.EllipsizeEnd)
 	title.AddCSSClass("heading")
+	if as := impersonationLabel(n.ClusterPreferences.Value()); as != "" {
+		title.SetText(fmt.Sprintf("%s (as %s)", n.ClusterPreferences.Value().Name, as))
+		title.AddCSSClass("warning")
+	}
 	header.SetTitleWidget(title)
 	header.SetShowEndTitleButtons(false)
 	header.SetShowStartTitleButtons(style.Eq(style.Darwin))
@@ -83,6 +88,8 @@ func NewNavigation(ctx context.Context, state *common.ClusterState, viewStack *g
 
 	windowSection := gio.NewMenu()
 	windowSection.Append("New Window", "win.newWindow")
+	windowSection.Append("View As…", "win.impersonate")
+	windowSection.Append("Cluster Health", "win.health")
 	windowSection.Append("Disconnect", "win.disconnect")
 
 	prefSection := gio.NewMenu()
@@ -98,6 +105,7 @@ func NewNavigation(ctx context.Context, state *common.ClusterState, viewStack *g
 	button.SetPopover(popover)
 
 	header.PackEnd(button)
+	header.PackEnd(NewPortForwardPanel(ctx, state))
 	n.AddTopBar(header)
 
 	content := gtk.NewBox(gtk.OrientationVertical, 4)
@@ -198,6 +206,9 @@ func NewNavigation(ctx context.Context, state *common.ClusterState, viewStack *g
 		resbin.SetChild(n.createResourceList(prefs))
 		n.updatePins(prefs.Navigation.Pins)
 	})
+	n.Resources.Sub(ctx, func(_ []metav1.APIResource) {
+		resbin.SetChild(n.createResourceList(n.ClusterPreferences.Value()))
+	})
 
 	resbin.SetChild(n.createResourceList(n.ClusterPreferences.Value()))
 	if len(n.favourites) > 0 {
@@ -208,11 +219,12 @@ func NewNavigation(ctx context.Context, state *common.ClusterState, viewStack *g
 }
 
 func (n *Navigation) createResourceList(prefs api.ClusterPreferences) *gtk.ListBox {
-	for inf, reg := range n.informerRegistrations {
-		inf.Informer().RemoveEventHandler(reg)
-		delete(n.informerRegistrations, inf)
+	if n.cancelStatus != nil {
+		n.cancelStatus()
 	}
+	n.statusCtx, n.cancelStatus = context.WithCancel(n.ctx)
 
+	resources := n.Resources.Value()
 	n.resourceList = gtk.NewListBox()
 	n.resourceList.AddCSSClass("navigation-sidebar")
 
@@ -222,7 +234,7 @@ func (n *Navigation) createResourceList(prefs api.ClusterPreferences) *gtk.ListB
 	pin.ConnectActivate(func(idx *glib.Variant) {
 		id, _ := strconv.Atoi(idx.String())
 		prefs := n.ClusterPreferences.Value()
-		prefs.Navigation.Favourites = append(prefs.Navigation.Favourites, util.GVRForResource(&n.Resources[id]))
+		prefs.Navigation.Favourites = append(prefs.Navigation.Favourites, util.GVRForResource(&resources[id]))
 		n.ClusterPreferences.Pub(prefs)
 	})
 	actionGroup.AddAction(pin)
@@ -231,7 +243,7 @@ func (n *Navigation) createResourceList(prefs api.ClusterPreferences) *gtk.ListB
 		id, _ := strconv.Atoi(idx.String())
 		prefs := n.ClusterPreferences.Value()
 		for i, f := range prefs.Navigation.Favourites {
-			if util.GVREquals(f, util.GVRForResource(&n.Resources[id])) {
+			if util.GVREquals(f, util.GVRForResource(&resources[id])) {
 				prefs.Navigation.Favourites = slices.Delete(prefs.Navigation.Favourites, i, i+1)
 				n.ClusterPreferences.Pub(prefs)
 				break
@@ -269,7 +281,7 @@ func (n *Navigation) createResourceList(prefs api.ClusterPreferences) *gtk.ListB
 		if err := json.Unmarshal([]byte(row.Name()), &gvr); err != nil {
 			return
 		}
-		for _, res := range n.Resources {
+		for _, res := range resources {
 			if util.GVREquals(util.GVRForResource(&res), gvr) && !util.ResourceEquals(n.SelectedResource.Value(), &res) {
 				n.SelectedResource.Pub(&res)
 				break
@@ -280,7 +292,7 @@ func (n *Navigation) createResourceList(prefs api.ClusterPreferences) *gtk.ListB
 	n.favourites = nil
 	n.resources = nil
 
-	for i, resource := range n.Resources {
+	for i, resource := range resources {
 		if len(n.search.Text()) > 0 {
 			if !strings.Contains(resource.Name, n.search.Text()) &&
 				strutil.Similarity(resource.Name, n.search.Text(), metrics.NewLevenshtein()) < 0.7 &&
@@ -442,24 +454,19 @@ func (n *Navigation) createResourceRow(resource *metav1.APIResource, idx int, fa
 	statusBox.Append(readyLabel)
 
 	if fav && n.Scheme.IsGroupRegistered(resource.Group) && slices.Contains(resource.Verbs, "watch") {
-		go func() {
-			informer := n.Cluster.GetInformer(util.GVRForResource(resource))
-			h := bindStatusCount(n.ctx, informer, func(m map[api.StatusType]int) {
-				glib.IdleAdd(func() {
-					readys := m[api.StatusSuccess]
-					readyLabel.SetVisible(readys > 0)
-					readyLabel.SetText(fmt.Sprintf("%d", readys))
-					errors := m[api.StatusError] + m[api.StatusWarning]
-					errorLabel.SetVisible(errors > 0)
-					errorLabel.SetText(fmt.Sprintf("%d", errors))
-				})
+		err := bindStatusCount(n.statusCtx, n.Cluster, util.GVRForResource(resource), func(m map[api.StatusType]int) {
+			glib.IdleAdd(func() {
+				readys := m[api.StatusSuccess]
+				readyLabel.SetVisible(readys > 0)
+				readyLabel.SetText(fmt.Sprintf("%d", readys))
+				errors := m[api.StatusError] + m[api.StatusWarning]
+				errorLabel.SetVisible(errors > 0)
+				errorLabel.SetText(fmt.Sprintf("%d", errors))
 			})
-			if h != nil {
-				glib.IdleAdd(func() {
-					n.informerRegistrations[informer] = h
-				})
-			}
-		}()
+		})
+		if err != nil {
+			klog.Infof("status count: %s", err)
+		}
 	}
 
 	gesture := gtk.NewGestureClick()
@@ -602,14 +609,13 @@ func (n *Navigation) RemovePin(object client.Object) {
 	}
 }
 
-func bindStatusCount(ctx context.Context, informer informers.GenericInformer, callback func(map[api.StatusType]int)) cache.ResourceEventHandlerRegistration {
-	if !cache.WaitForCacheSync(ctx.Done(), informer.Informer().HasSynced) {
-		return nil
-	}
-
+// bindStatusCount calls back with the number of objects of the resource per
+// status, shortly after they changed. The informer is looked up for each
+// count, as it's replaced when the cluster reconnects.
+func bindStatusCount(ctx context.Context, cluster *api.Cluster, gvr schema.GroupVersionResource, callback func(map[api.StatusType]int)) error {
 	updateLabels, _ := debounce.Debounce(func() {
 		var objects []client.Object
-		err := cache.ListAll(informer.Informer().GetIndexer(), labels.Everything(), func(m interface{}) {
+		err := cache.ListAll(cluster.GetInformer(gvr).Informer().GetIndexer(), labels.Everything(), func(m interface{}) {
 			objects = append(objects, m.(client.Object))
 		})
 		if err != nil {
@@ -625,14 +631,15 @@ func bindStatusCount(ctx context.Context, informer informers.GenericInformer, ca
 		callback(statuses)
 	}, time.Second)
 
-	reg, _ := informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+	return cluster.AddInformerEventHandler(ctx, gvr, cache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
 			updateLabels()
 		},
 		UpdateFunc: func(oldObj, newObj interface{}) {
 			updateLabels()
 		},
+		DeleteFunc: func(obj interface{}) {
+			updateLabels()
+		},
 	})
-
-	return reg
 }
@@ -0,0 +1,242 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/getseabird/seabird/api"
+	"github.com/getseabird/seabird/internal/ctxt"
+	"github.com/getseabird/seabird/internal/ui/common"
+	"github.com/getseabird/seabird/widget"
+)
+
+// PortForwardPanel is a header button with a panel that lists the active port
+// forwards and the saved profiles of the cluster.
+type PortForwardPanel struct {
+	*gtk.MenuButton
+	*common.ClusterState
+	ctx      context.Context
+	popover  *gtk.Popover
+	active   *gtk.ListBox
+	profiles *gtk.ListBox
+}
+
+func NewPortForwardPanel(ctx context.Context, state *common.ClusterState) *PortForwardPanel {
+	p := &PortForwardPanel{
+		MenuButton:   gtk.NewMenuButton(),
+		ClusterState: state,
+		ctx:          ctx,
+		popover:      gtk.NewPopover(),
+		active:       gtk.NewListBox(),
+		profiles:     gtk.NewListBox(),
+	}
+	p.SetIconName("vertical-arrows-long-symbolic")
+	p.SetTooltipText("Port forwards")
+	p.SetPopover(p.popover)
+
+	box := gtk.NewBox(gtk.OrientationVertical, 8)
+	box.SetSizeRequest(360, -1)
+	for _, section := range []struct {
+		title string
+		list  *gtk.ListBox
+	}{{"Active", p.active}, {"Profiles", p.profiles}} {
+		label := gtk.NewLabel(section.title)
+		label.AddCSSClass("heading")
+		label.SetHAlign(gtk.AlignStart)
+		box.Append(label)
+		section.list.AddCSSClass("boxed-list")
+		section.list.SetSelectionMode(gtk.SelectionNone)
+		box.Append(section.list)
+	}
+	scrolled := gtk.NewScrolledWindow()
+	scrolled.SetPolicy(gtk.PolicyNever, gtk.PolicyAutomatic)
+	scrolled.SetPropagateNaturalHeight(true)
+	scrolled.SetMaxContentHeight(480)
+	scrolled.SetChild(box)
+	p.popover.SetChild(scrolled)
+
+	p.popover.ConnectShow(p.update)
+	p.PortForwards.Forwards.Sub(ctx, func(forwards []api.PortForward) {
+		if len(forwards) > 0 {
+			p.AddCSSClass("accent")
+		} else {
+			p.RemoveCSSClass("accent")
+		}
+		if p.popover.Visible() {
+			p.update()
+		}
+	})
+	p.ClusterPreferences.Sub(ctx, func(api.ClusterPreferences) {
+		if p.popover.Visible() {
+			p.update()
+		}
+	})
+
+	return p
+}
+
+func (p *PortForwardPanel) update() {
+	p.active.RemoveAll()
+	forwards := p.PortForwards.Forwards.Value()
+	if len(forwards) == 0 {
+		p.active.Append(placeholderRow("No active port forwards"))
+	}
+	for _, fwd := range forwards {
+		row := adw.NewActionRow()
+		row.SetTitle(fmt.Sprintf("%s/%s", fwd.Namespace, fwd.Target))
+		subtitle := fmt.Sprintf("%s · %s", strings.Join(fwd.Ports, ", "), fwd.Status)
+		if fwd.Address != "" {
+			subtitle = fmt.Sprintf("%s · %s", fwd.Address, subtitle)
+		}
+		if fwd.Kind() != "pod" && fwd.Pod != "" {
+			subtitle = fmt.Sprintf("%s · %s", fwd.Pod, subtitle)
+		}
+		if fwd.Error != nil {
+			subtitle = fmt.Sprintf("%s: %s", subtitle, fwd.Error)
+		}
+		row.SetSubtitle(subtitle)
+		row.SetSubtitleLines(2)
+
+		for _, port := range fwd.Ports {
+			_, remote := api.SplitPort(port)
+			row.AddSuffix(widget.NewPortForwardLink(p.ctx, fwd, remote))
+		}
+
+		save := gtk.NewButtonFromIconName("document-save-symbolic")
+		save.AddCSSClass("flat")
+		save.SetVAlign(gtk.AlignCenter)
+		save.SetTooltipText("Save as profile")
+		save.ConnectClicked(func() {
+			p.popover.Popdown()
+			p.showSaveDialog(fwd)
+		})
+		row.AddSuffix(save)
+
+		stop := gtk.NewButtonFromIconName("cross-small-symbolic")
+		stop.AddCSSClass("flat")
+		stop.SetVAlign(gtk.AlignCenter)
+		stop.SetTooltipText("Stop")
+		stop.ConnectClicked(func() {
+			p.PortForwards.Stop(fwd.ID)
+		})
+		row.AddSuffix(stop)
+
+		p.active.Append(row)
+	}
+
+	p.profiles.RemoveAll()
+	profiles := p.ClusterPreferences.Value().PortForwards
+	if len(profiles) == 0 {
+		p.profiles.Append(placeholderRow("No saved profiles"))
+	}
+	for i, profile := range profiles {
+		row := adw.NewActionRow()
+		row.SetTitle(profile.Name)
+		row.SetSubtitle(fmt.Sprintf("%s/%s · %s", profile.Namespace, profile.Target, strings.Join(profile.Ports, ", ")))
+
+		restore := gtk.NewCheckButton()
+		restore.SetActive(profile.Restore)
+		restore.SetVAlign(gtk.AlignCenter)
+		restore.SetTooltipText("Restore when connecting")
+		restore.ConnectToggled(func() {
+			prefs := p.ClusterPreferences.Value()
+			prefs.PortForwards = slices.Clone(prefs.PortForwards)
+			prefs.PortForwards[i].Restore = restore.Active()
+			p.ClusterPreferences.Pub(prefs)
+		})
+		row.AddSuffix(restore)
+
+		start := gtk.NewButtonFromIconName("play-symbolic")
+		start.AddCSSClass("flat")
+		start.SetVAlign(gtk.AlignCenter)
+		start.SetTooltipText("Start")
+		start.ConnectClicked(func() {
+			start.SetSensitive(false)
+			go func() {
+				_, err := p.PortForwards.StartProfile(profile)
+				glib.IdleAdd(func() {
+					start.SetSensitive(true)
+					if err != nil {
+						widget.ShowErrorDialog(p.ctx, "Port forward error", err)
+					}
+				})
+			}()
+		})
+		row.AddSuffix(start)
+
+		remove := gtk.NewButtonFromIconName("user-trash-symbolic")
+		remove.AddCSSClass("flat")
+		remove.SetVAlign(gtk.AlignCenter)
+		remove.SetTooltipText("Delete profile")
+		remove.ConnectClicked(func() {
+			prefs := p.ClusterPreferences.Value()
+			prefs.PortForwards = slices.Delete(slices.Clone(prefs.PortForwards), i, i+1)
+			p.ClusterPreferences.Pub(prefs)
+		})
+		row.AddSuffix(remove)
+
+		p.profiles.Append(row)
+	}
+}
+
+func (p *PortForwardPanel) showSaveDialog(fwd api.PortForward) {
+	entry := gtk.NewEntry()
+	entry.SetText(fwd.Target)
+	restore := gtk.NewCheckButtonWithLabel("Restore when connecting")
+	box := gtk.NewBox(gtk.OrientationVertical, 12)
+	box.Append(entry)
+	box.Append(restore)
+
+	dialog := adw.NewMessageDialog(ctxt.MustFrom[*gtk.Window](p.ctx), "Save Port Forward", "Save the forward as a named profile")
+	dialog.SetExtraChild(box)
+	dialog.AddResponse("cancel", "Cancel")
+	dialog.AddResponse("save", "Save")
+	dialog.SetResponseAppearance("save", adw.ResponseSuggested)
+	dialog.SetCloseResponse("cancel")
+	dialog.ConnectResponse(func(response string) {
+		if response != "save" || entry.Text() == "" {
+			return
+		}
+		prefs := p.ClusterPreferences.Value()
+		prefs.PortForwards = append(slices.Clone(prefs.PortForwards), api.PortForwardProfile{
+			PortForwardSpec: fwd.PortForwardSpec,
+			Name:            entry.Text(),
+			Restore:         restore.Active(),
+		})
+		p.ClusterPreferences.Pub(prefs)
+	})
+	dialog.Present()
+}
+
+// restorePortForwards starts the saved profiles marked for restoring and
+// reports failures as toasts.
+func restorePortForwards(ctx context.Context, cluster *api.Cluster) {
+	go func() {
+		errs := cluster.PortForwards.Restore()
+		glib.IdleAdd(func() {
+			toast, ok := ctxt.From[*adw.ToastOverlay](ctx)
+			if !ok {
+				return
+			}
+			for name, err := range errs {
+				toast.AddToast(adw.NewToast(fmt.Sprintf("Could not restore port forward %s: %s", name, err)))
+			}
+		})
+	}()
+}
+
+func placeholderRow(text string) *gtk.ListBoxRow {
+	label := gtk.NewLabel(text)
+	label.AddCSSClass("dim-label")
+	label.SetMarginTop(12)
+	label.SetMarginBottom(12)
+	row := gtk.NewListBoxRow()
+	row.SetChild(label)
+	row.SetActivatable(false)
+	return row
+}
@@ -51,6 +51,17 @@ func NewWelcomeWindow(ctx context.Context, app *gtk.Application, state *common.S
 	w.content.SetChild(w.createContent(true))
 	w.SetTitle(ApplicationName)
 
+	ctx, cancel := context.WithCancel(ctx)
+	clusters := len(state.Preferences.Value().Clusters)
+	state.Preferences.Sub(ctx, func(prefs api.Preferences) {
+		// Show contexts added to kubeconfig files, unless a subpage is open
+		if len(prefs.Clusters) == clusters || w.nav.PreviousPage(w.nav.VisiblePage()) != nil {
+			return
+		}
+		clusters = len(prefs.Clusters)
+		w.content.SetChild(w.createContent(false))
+	})
+
 	go w.showUpdateNotification()
 
 	var h glib.SignalHandle
@@ -64,6 +75,7 @@ func NewWelcomeWindow(ctx context.Context, app *gtk.Application, state *common.S
 			w.HandlerDisconnect(h)
 			return true
 		}
+		cancel()
 		return false
 	})
 
@@ -0,0 +1,24 @@
+package util
+
+import "testing"
+
+func TestHumanizeBytes(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KiB"},
+		{1536, "1.5 KiB"},
+		{1024*1024 - 1, "1024.0 KiB"},
+		{1024 * 1024, "1.0 MiB"},
+		{5 * 1024 * 1024 * 1024, "5.0 GiB"},
+		{1 << 60, "1.0 EiB"},
+	}
+	for _, tt := range tests {
+		if got := HumanizeBytes(tt.n); got != tt.want {
+			t.Errorf("HumanizeBytes(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,303 @@
+package widget
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/getseabird/seabird/api"
+	"github.com/getseabird/seabird/internal/ctxt"
+	"github.com/getseabird/seabird/internal/util"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// ShowUploadDialog asks for a destination directory in the container and a
+// local file or folder, which is then copied like kubectl cp does. The
+// container needs to have tar.
+func ShowUploadDialog(ctx context.Context, cluster *api.Cluster, pod *corev1.Pod, container string) {
+	entry := gtk.NewEntry()
+	entry.SetText("/tmp")
+	dialog := adw.NewMessageDialog(ctxt.MustFrom[*gtk.Window](ctx), "Upload", fmt.Sprintf("Copy to a directory in container %s", container))
+	dialog.SetExtraChild(entry)
+	dialog.AddResponse("cancel", "Cancel")
+	dialog.AddResponse("folder", "Folder…")
+	dialog.AddResponse("file", "File…")
+	dialog.SetResponseAppearance("file", adw.ResponseSuggested)
+	dialog.SetCloseResponse("cancel")
+	dialog.ConnectResponse(func(response string) {
+		action := gtk.FileChooserActionOpen
+		switch response {
+		case "file":
+		case "folder":
+			action = gtk.FileChooserActionSelectFolder
+		default:
+			return
+		}
+		dest := entry.Text()
+
+		chooser := gtk.NewFileChooserNative("Upload", ctxt.MustFrom[*gtk.Window](ctx), action, "Upload", "Cancel")
+		defer chooser.Show()
+		chooser.ConnectResponse(func(responseId int) {
+			if responseId != int(gtk.ResponseAccept) {
+				return
+			}
+			src := chooser.File().Path()
+			total, err := localSize(src)
+			if err != nil {
+				ShowErrorDialog(ctx, "Upload failed", err)
+				return
+			}
+			runTransfer(ctx, "Uploading", filepath.Base(src), total, func(ctx context.Context, progress *atomic.Int64) error {
+				return uploadTar(ctx, cluster, pod, container, src, dest, progress)
+			})
+		})
+	})
+	dialog.Present()
+}
+
+// ShowDownloadDialog asks for a file or directory in the container and a local
+// folder to extract it to. The container needs to have tar.
+func ShowDownloadDialog(ctx context.Context, cluster *api.Cluster, pod *corev1.Pod, container string) {
+	entry := gtk.NewEntry()
+	entry.SetPlaceholderText("/path/to/file")
+	dialog := adw.NewMessageDialog(ctxt.MustFrom[*gtk.Window](ctx), "Download", fmt.Sprintf("File or directory in container %s", container))
+	dialog.SetExtraChild(entry)
+	dialog.AddResponse("cancel", "Cancel")
+	dialog.AddResponse("download", "Download…")
+	dialog.SetResponseAppearance("download", adw.ResponseSuggested)
+	dialog.SetCloseResponse("cancel")
+	dialog.ConnectResponse(func(response string) {
+		src := path.Clean(entry.Text())
+		if response != "download" || src == "." || src == "/" {
+			return
+		}
+
+		chooser := gtk.NewFileChooserNative("Download to", ctxt.MustFrom[*gtk.Window](ctx), gtk.FileChooserActionSelectFolder, "Download", "Cancel")
+		defer chooser.Show()
+		chooser.ConnectResponse(func(responseId int) {
+			if responseId != int(gtk.ResponseAccept) {
+				return
+			}
+			dest := chooser.File().Path()
+			runTransfer(ctx, "Downloading", path.Base(src), -1, func(ctx context.Context, progress *atomic.Int64) error {
+				return downloadTar(ctx, cluster, pod, container, src, dest, progress)
+			})
+		})
+	})
+	dialog.Present()
+}
+
+// runTransfer shows a progress dialog while transfer runs in the background.
+// If total is unknown (-1), only the transferred bytes are shown.
+func runTransfer(ctx context.Context, title, name string, total int64, transfer func(context.Context, *atomic.Int64) error) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	bar := gtk.NewProgressBar()
+	bar.SetShowText(true)
+	dialog := adw.NewMessageDialog(ctxt.MustFrom[*gtk.Window](ctx), title, name)
+	dialog.SetExtraChild(bar)
+	dialog.AddResponse("cancel", "Cancel")
+	dialog.SetCloseResponse("cancel")
+	dialog.ConnectResponse(func(string) { cancel() })
+	dialog.Present()
+
+	var progress atomic.Int64
+	update := func() {
+		n := progress.Load()
+		if total > 0 {
+			bar.SetFraction(min(float64(n)/float64(total), 1))
+			bar.SetText(fmt.Sprintf("%s / %s", util.HumanizeBytes(n), util.HumanizeBytes(total)))
+		} else {
+			bar.Pulse()
+			bar.SetText(util.HumanizeBytes(n))
+		}
+	}
+	glib.TimeoutAdd(200, func() bool {
+		update()
+		return ctx.Err() == nil
+	})
+
+	go func() {
+		err := transfer(ctx, &progress)
+		canceled := ctx.Err() != nil
+		cancel()
+		glib.IdleAdd(func() {
+			dialog.Close()
+			if err != nil && !canceled {
+				ShowErrorDialog(ctx, fmt.Sprintf("%s failed", title), err)
+				return
+			}
+			if toast, ok := ctxt.From[*adw.ToastOverlay](ctx); ok && !canceled {
+				toast.AddToast(adw.NewToast(fmt.Sprintf("%s copied", name)))
+			}
+		})
+	}()
+}
+
+// uploadTar streams src as tar archive into tar running in the container,
+// which extracts it into dest.
+func uploadTar(ctx context.Context, cluster *api.Cluster, pod *corev1.Pod, container, src, dest string, progress *atomic.Int64) error {
+	r, w := io.Pipe()
+	go func() {
+		w.CloseWithError(writeTar(w, src, progress))
+	}()
+
+	var stderr bytes.Buffer
+	err := podExec(ctx, cluster, pod, container, []string{"tar", "-xmf", "-", "-C", dest}, r, io.Discard, &stderr, false, nil)
+	r.Close()
+	return execError(err, &stderr)
+}
+
+// downloadTar runs tar in the container and extracts its output into dest.
+func downloadTar(ctx context.Context, cluster *api.Cluster, pod *corev1.Pod, container, src, dest string, progress *atomic.Int64) error {
+	r, w := io.Pipe()
+	var stderr bytes.Buffer
+	go func() {
+		err := podExec(ctx, cluster, pod, container, []string{"tar", "-cf", "-", "-C", path.Dir(src), path.Base(src)}, nil, w, &stderr, false, nil)
+		w.CloseWithError(execError(err, &stderr))
+	}()
+
+	err := readTar(&progressReader{Reader: r, progress: progress}, dest)
+	r.Close()
+	return err
+}
+
+func execError(err error, stderr *bytes.Buffer) error {
+	if err != nil && stderr.Len() > 0 {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return err
+}
+
+// writeTar archives the file or directory with its base name as root.
+func writeTar(w io.Writer, src string, progress *atomic.Int64) error {
+	tw := tar.NewWriter(w)
+	base := filepath.Dir(src)
+	err := filepath.WalkDir(src, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() && !info.IsDir() {
+			klog.Infof("skipping %s: not a regular file", file)
+			return nil
+		}
+		name, err := filepath.Rel(base, file)
+		if err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(name)
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, &progressReader{Reader: f, progress: progress})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// readTar extracts the archive into dest. Entries that would end up outside
+// of dest, as well as links, are skipped.
+func readTar(r io.Reader, dest string) error {
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		name := filepath.Join(dest, filepath.FromSlash(path.Clean("/"+header.Name)))
+		if !strings.HasPrefix(name, filepath.Clean(dest)+string(filepath.Separator)) {
+			klog.Infof("skipping %s: outside of destination", header.Name)
+			continue
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(name, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, header.FileInfo().Mode().Perm())
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(f, tr); err != nil {
+				f.Close()
+				return err
+			}
+			if err := f.Close(); err != nil {
+				return err
+			}
+		default:
+			klog.Infof("skipping %s: unsupported type", header.Name)
+		}
+	}
+}
+
+func localSize(src string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(src, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+type progressReader struct {
+	io.Reader
+	progress *atomic.Int64
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.progress.Add(int64(n))
+	return n, err
+}
@@ -0,0 +1,111 @@
+package widget
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestReadTar(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []tar.Header
+		want    []string
+	}{
+		{
+			name: "files and directories",
+			entries: []tar.Header{
+				{Name: "dir/", Typeflag: tar.TypeDir, Mode: 0755},
+				{Name: "dir/file", Typeflag: tar.TypeReg, Mode: 0644},
+				{Name: "top", Typeflag: tar.TypeReg, Mode: 0644},
+			},
+			want: []string{"dir", "dir/file", "top"},
+		},
+		{
+			name: "parent references stay inside",
+			entries: []tar.Header{
+				{Name: "../escaped", Typeflag: tar.TypeReg, Mode: 0644},
+				{Name: "dir/../../../escaped2", Typeflag: tar.TypeReg, Mode: 0644},
+			},
+			want: []string{"escaped", "escaped2"},
+		},
+		{
+			name: "absolute paths stay inside",
+			entries: []tar.Header{
+				{Name: "/etc/passwd", Typeflag: tar.TypeReg, Mode: 0644},
+			},
+			want: []string{"etc", "etc/passwd"},
+		},
+		{
+			name: "root entry is skipped",
+			entries: []tar.Header{
+				{Name: "/", Typeflag: tar.TypeDir, Mode: 0755},
+				{Name: "..", Typeflag: tar.TypeReg, Mode: 0644},
+			},
+		},
+		{
+			name: "links are skipped",
+			entries: []tar.Header{
+				{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"},
+				{Name: "hard", Typeflag: tar.TypeLink, Linkname: "../outside"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tw := tar.NewWriter(&buf)
+			for _, header := range tt.entries {
+				var data []byte
+				if header.Typeflag == tar.TypeReg {
+					data = []byte(header.Name)
+					header.Size = int64(len(data))
+				}
+				if err := tw.WriteHeader(&header); err != nil {
+					t.Fatal(err)
+				}
+				if _, err := tw.Write(data); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := tw.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			root := t.TempDir()
+			dest := filepath.Join(root, "dest")
+			if err := os.Mkdir(dest, 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := readTar(&buf, dest); err != nil {
+				t.Fatalf("readTar() error = %v", err)
+			}
+
+			var got []string
+			err := filepath.Walk(root, func(path string, _ os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				rel, err := filepath.Rel(dest, path)
+				if err != nil {
+					return err
+				}
+				if path == root || path == dest {
+					return nil
+				}
+				got = append(got, filepath.ToSlash(rel))
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("extracted %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
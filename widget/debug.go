@@ -0,0 +1,112 @@
+package widget
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/getseabird/seabird/api"
+	"github.com/getseabird/seabird/internal/ctxt"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// ShowDebugDialog adds an ephemeral debug container to the pod, like kubectl
+// debug does, and attaches a terminal to its main process once it runs. If
+// target is set, the debug container can share its process namespace.
+func ShowDebugDialog(ctx context.Context, cluster *api.Cluster, pod *corev1.Pod, target string, nav *adw.NavigationView) {
+	box := gtk.NewBox(gtk.OrientationVertical, 12)
+	image := gtk.NewEntry()
+	image.SetText(cluster.ClusterPreferences.Value().Terminal.DebugImage)
+	box.Append(image)
+	share := gtk.NewCheckButtonWithLabel(fmt.Sprintf("Share process namespace with %s", target))
+	share.SetActive(true)
+	share.SetVisible(target != "")
+	box.Append(share)
+
+	dialog := adw.NewMessageDialog(ctxt.MustFrom[*gtk.Window](ctx), "Debug", "Start an ephemeral container with this image")
+	dialog.SetExtraChild(box)
+	dialog.AddResponse("cancel", "Cancel")
+	dialog.AddResponse("debug", "Debug")
+	dialog.SetResponseAppearance("debug", adw.ResponseSuggested)
+	dialog.SetCloseResponse("cancel")
+	dialog.ConnectResponse(func(response string) {
+		if response != "debug" {
+			return
+		}
+		container := corev1.EphemeralContainer{
+			EphemeralContainerCommon: corev1.EphemeralContainerCommon{
+				Name:                     fmt.Sprintf("debugger-%s", utilrand.String(5)),
+				Image:                    image.Text(),
+				Stdin:                    true,
+				TTY:                      true,
+				TerminationMessagePolicy: corev1.TerminationMessageReadFile,
+			},
+		}
+		if share.Active() && target != "" {
+			container.TargetContainerName = target
+		}
+
+		if toast, ok := ctxt.From[*adw.ToastOverlay](ctx); ok {
+			toast.AddToast(adw.NewToast(fmt.Sprintf("Starting debug container %s", container.Name)))
+		}
+		go func() {
+			pod, err := startEphemeralContainer(ctx, cluster, pod, container)
+			glib.IdleAdd(func() {
+				if err != nil {
+					ShowErrorDialog(ctx, "Debug container failed", err)
+					return
+				}
+				nav.Push(NewAttachPage(ctx, cluster, pod, container.Name).NavigationPage)
+			})
+		}()
+	})
+	dialog.Present()
+}
+
+// startEphemeralContainer adds the container to the pod and waits until it
+// runs.
+func startEphemeralContainer(ctx context.Context, cluster *api.Cluster, pod *corev1.Pod, container corev1.EphemeralContainer) (*corev1.Pod, error) {
+	patch, err := json.Marshal(map[string]any{
+		"spec": map[string]any{
+			"ephemeralContainers": []corev1.EphemeralContainer{container},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	pods := cluster.CoreV1().Pods(pod.Namespace)
+	if _, err := pods.Patch(ctx, pod.Name, types.StrategicMergePatchType, patch, metav1.PatchOptions{}, "ephemeralcontainers"); err != nil {
+		return nil, err
+	}
+
+	var running *corev1.Pod
+	err = wait.PollUntilContextTimeout(ctx, time.Second, 2*time.Minute, true, func(ctx context.Context) (bool, error) {
+		pod, err := pods.Get(ctx, pod.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		for _, status := range pod.Status.EphemeralContainerStatuses {
+			if status.Name != container.Name {
+				continue
+			}
+			if status.State.Terminated != nil {
+				return false, fmt.Errorf("container terminated: %s", status.State.Terminated.Reason)
+			}
+			if status.State.Running != nil {
+				running = pod
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+
+	return running, err
+}
@@ -0,0 +1,176 @@
+package widget
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/getseabird/seabird/api"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+	"k8s.io/client-go/util/exec"
+)
+
+var errNoShell = errors.New("no shell found")
+
+// podExec runs the command in the container. Streams that are nil are not
+// attached. With tty set, stderr is merged into stdout by the remote TTY.
+func podExec(ctx context.Context, cluster *api.Cluster, pod *corev1.Pod, container string, command []string, stdin io.Reader, stdout io.Writer, stderr io.Writer, tty bool, sizeQueue remotecommand.TerminalSizeQueue) error {
+	if tty {
+		stderr = nil
+	}
+	req := cluster.CoreV1().RESTClient().Post().Resource("pods").Name(pod.Name).Namespace(pod.Namespace).SubResource("exec")
+	option := &corev1.PodExecOptions{
+		Container: container,
+		Command:   command,
+		Stdin:     stdin != nil,
+		Stdout:    stdout != nil,
+		Stderr:    stderr != nil,
+		TTY:       tty,
+	}
+	req.VersionedParams(
+		option,
+		scheme.ParameterCodec,
+	)
+
+	return streamSubresource(ctx, cluster, req, stdin, stdout, stderr, tty, sizeQueue)
+}
+
+// podAttach connects the streams to the main process of the container.
+// Canceling ctx detaches without stopping the process.
+func podAttach(ctx context.Context, cluster *api.Cluster, pod *corev1.Pod, container string, stdin io.Reader, stdout io.Writer, stderr io.Writer, tty bool, sizeQueue remotecommand.TerminalSizeQueue) error {
+	if tty {
+		stderr = nil
+	}
+	req := cluster.CoreV1().RESTClient().Post().Resource("pods").Name(pod.Name).Namespace(pod.Namespace).SubResource("attach")
+	req.VersionedParams(
+		&corev1.PodAttachOptions{
+			Container: container,
+			Stdin:     stdin != nil,
+			Stdout:    stdout != nil,
+			Stderr:    stderr != nil,
+			TTY:       tty,
+		},
+		scheme.ParameterCodec,
+	)
+
+	return streamSubresource(ctx, cluster, req, stdin, stdout, stderr, tty, sizeQueue)
+}
+
+func streamSubresource(ctx context.Context, cluster *api.Cluster, req *rest.Request, stdin io.Reader, stdout io.Writer, stderr io.Writer, tty bool, sizeQueue remotecommand.TerminalSizeQueue) error {
+	spdy, err := remotecommand.NewSPDYExecutor(cluster.Config(), "POST", req.URL())
+	if err != nil {
+		return err
+	}
+	ws, err := remotecommand.NewWebSocketExecutor(cluster.Config(), "GET", req.URL().String())
+	if err != nil {
+		return err
+	}
+	exec, err := remotecommand.NewFallbackExecutor(ws, spdy, httpstream.IsUpgradeFailure)
+	if err != nil {
+		return err
+	}
+
+	return exec.StreamWithContext(ctx, remotecommand.StreamOptions{
+		Stdin:             stdin,
+		Stdout:            stdout,
+		Stderr:            stderr,
+		Tty:               tty,
+		TerminalSizeQueue: sizeQueue,
+	})
+}
+
+// detectShell returns the first of the shells that exists in the container,
+// or errNoShell if none does.
+func detectShell(ctx context.Context, cluster *api.Cluster, pod *corev1.Pod, container string, shells []string) (string, error) {
+	for _, shell := range shells {
+		err := podExec(ctx, cluster, pod, container, []string{shell, "-c", "exit 0"}, nil, io.Discard, io.Discard, false, nil)
+		var exitErr exec.ExitError
+		switch {
+		case err == nil:
+			return shell, nil
+		case isCommandNotFound(err):
+			continue
+		case errors.As(err, &exitErr):
+			// The shell exists but failed for some other reason
+			return shell, nil
+		default:
+			return "", err
+		}
+	}
+	return "", errNoShell
+}
+
+// isCommandNotFound reports whether the exec failed because the executable
+// doesn't exist. Depending on the runtime this is either reported as exit code
+// 126/127 or as an error message.
+func isCommandNotFound(err error) bool {
+	var exitErr exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitStatus() == 126 || exitErr.ExitStatus() == 127
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "no such file or directory") || strings.Contains(msg, "executable file not found")
+}
+
+// splitCommand splits a command line into arguments. Single and double quotes
+// group arguments, a backslash escapes the next character.
+func splitCommand(command string) ([]string, error) {
+	var (
+		args    []string
+		current strings.Builder
+		inArg   bool
+		quote   rune
+		escaped bool
+	)
+	for _, r := range command {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inArg = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, fmt.Errorf("unterminated quote or escape in '%s'", command)
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
+
+// commandHistoryKey identifies a container across pod restarts by the pod's
+// owner.
+func commandHistoryKey(pod *corev1.Pod, container string) string {
+	name := pod.Name
+	if len(pod.OwnerReferences) > 0 {
+		name = pod.OwnerReferences[0].Name
+	}
+	return fmt.Sprintf("%s/%s/%s", pod.Namespace, name, container)
+}
@@ -0,0 +1,40 @@
+package widget
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitCommand(t *testing.T) {
+	tests := []struct {
+		command string
+		want    []string
+		wantErr bool
+	}{
+		{command: "", want: nil},
+		{command: "   ", want: nil},
+		{command: "ls", want: []string{"ls"}},
+		{command: "ls  -la\t/tmp\n", want: []string{"ls", "-la", "/tmp"}},
+		{command: `sh -c "echo hello world"`, want: []string{"sh", "-c", "echo hello world"}},
+		{command: `echo 'a "b" c'`, want: []string{"echo", `a "b" c`}},
+		{command: `echo "it's"`, want: []string{"echo", "it's"}},
+		{command: `echo a\ b`, want: []string{"echo", "a b"}},
+		{command: `echo 'a\b'`, want: []string{"echo", `a\b`}},
+		{command: `echo "a\"b"`, want: []string{"echo", `a"b`}},
+		{command: `echo ""`, want: []string{"echo", ""}},
+		{command: `echo pre"fix"`, want: []string{"echo", "prefix"}},
+		{command: `echo "unterminated`, wantErr: true},
+		{command: `echo trailing\`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			got, err := splitCommand(tt.command)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("splitCommand() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitCommand() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
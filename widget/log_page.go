@@ -1,76 +1,953 @@
 package widget
 
 import (
+	"bufio"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"hash/fnv"
 	"html"
 	"io"
+	"math"
+	"regexp"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
 	"github.com/diamondburned/gotk4-sourceview/pkg/gtksource/v5"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
 	"github.com/diamondburned/gotk4/pkg/gtk/v4"
 	"github.com/getseabird/seabird/api"
 	"github.com/getseabird/seabird/internal/util"
 	"github.com/leaanthony/go-ansi-parser"
+	"github.com/zmwangx/debounce"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
 )
 
 type LogPage struct {
 	*adw.NavigationPage
+	ctx        context.Context
+	cluster    *api.Cluster
+	pod        *corev1.Pod
+	container  string
+	filter     func(*corev1.Pod) bool
+	following  map[string]bool
+	sources    []*logSource
+	opts       corev1.PodLogOptions
+	cancel     context.CancelFunc
+	generation int
+	buffer     *gtksource.Buffer
+	view       *gtksource.View
+	endMark    *gtk.TextMark
+	search     *gtksource.SearchContext
+	match      func(string) bool
+	maxLines   int
+	// expandFields is whether the fields of new structured lines are shown.
+	// Each line can be toggled by clicking it.
+	expandFields bool
+	// lines holds the most recent lines, shown holds the number of buffer
+	// rows of each line currently in the buffer and rendering the lines left
+	// to insert while the buffer is rendered again.
+	lines      *ring[logLine]
+	shown      *ring[int]
+	rendering  []logLine
+	autoscroll bool
+	paused     bool
+	mutex      sync.Mutex
+	pending    *ring[logLine]
+	scheduled  bool
 }
 
+const (
+	defaultMaxLogLines = 10000
+	// logBatchSize limits the lines added to the buffer per main loop
+	// iteration, so the UI stays responsive while large logs load.
+	logBatchSize = 500
+)
+
+// logLine is a received line along with its rendering, which is prepared off
+// the main loop.
+type logLine struct {
+	generation int
+	source     *logSource
+	text       string
+	note       bool
+	plain      string
+	markup     string
+	fields     string
+	rows       int
+}
+
+// logSource is a single container whose logs are shown on the page. Sources
+// of aggregated pages have a prefix to tell their lines apart.
+type logSource struct {
+	pod       *corev1.Pod
+	container string
+	prefix    string
+	color     string
+}
+
+var logPrefixColors = []string{"#1c71d8", "#26a269", "#e66100", "#9141ac", "#c01c28", "#986a44", "#2190a4", "#c64600", "#613583", "#5e5c64"}
+
+// NewLogPage shows the logs of a single container.
 func NewLogPage(ctx context.Context, cluster *api.Cluster, pod *corev1.Pod, container string) *LogPage {
+	p := newLogPage(ctx, cluster, container)
+	p.pod = pod
+	p.container = container
+	p.reload()
+	return p
+}
+
+// NewAggregatedLogPage shows the logs of all containers in pods matching the
+// filter. Pods created while the page is open are picked up automatically.
+func NewAggregatedLogPage(ctx context.Context, cluster *api.Cluster, title string, filter func(*corev1.Pod) bool) *LogPage {
+	p := newLogPage(ctx, cluster, title)
+	p.filter = filter
+	p.reload()
+	return p
+}
+
+func newLogPage(ctx context.Context, cluster *api.Cluster, title string) *LogPage {
 	box := gtk.NewBox(gtk.OrientationVertical, 0)
 	box.AddCSSClass("view")
-	p := LogPage{NavigationPage: adw.NewNavigationPage(box, container)}
+	p := LogPage{
+		NavigationPage: adw.NewNavigationPage(box, title),
+		cluster:        cluster,
+		maxLines:       defaultMaxLogLines,
+		expandFields:   true,
+		autoscroll:     true,
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	p.ConnectHidden(cancel)
+	p.ctx = ctx
 
 	header := adw.NewHeaderBar()
 	header.SetShowStartTitleButtons(false)
 	header.AddCSSClass("flat")
 	box.Append(header)
 
-	buffer := gtksource.NewBuffer(nil)
-	util.SetSourceColorScheme(buffer)
-	view := gtksource.NewViewWithBuffer(buffer)
-	view.SetEditable(false)
-	view.SetWrapMode(gtk.WrapWord)
-	view.SetShowLineNumbers(true)
-	view.SetMonospace(true)
+	pause := gtk.NewToggleButton()
+	pause.SetIconName("pause-symbolic")
+	pause.SetTooltipText("Pause")
+	pause.ConnectToggled(func() {
+		p.paused = pause.Active()
+		if p.paused {
+			pause.SetIconName("play-symbolic")
+			pause.SetTooltipText("Resume")
+		} else {
+			pause.SetIconName("pause-symbolic")
+			pause.SetTooltipText("Pause")
+			p.flush()
+		}
+	})
+	header.PackEnd(pause)
+	header.PackEnd(p.createOptions())
+	header.PackEnd(p.createSaveMenu())
+
+	p.buffer = gtksource.NewBuffer(nil)
+	util.SetSourceColorScheme(p.buffer)
+	p.endMark = p.buffer.CreateMark("end", p.buffer.EndIter(), false)
+	for _, name := range []string{"fields", "fields-collapsed"} {
+		fields := gtk.NewTextTag(name)
+		fields.SetObjectProperty("left-margin", 32)
+		fields.SetObjectProperty("foreground", "#77767b")
+		fields.SetObjectProperty("invisible", name == "fields-collapsed")
+		p.buffer.TagTable().Add(fields)
+	}
+	p.view = gtksource.NewViewWithBuffer(p.buffer)
+	p.view.SetEditable(false)
+	p.view.SetWrapMode(gtk.WrapWord)
+	p.view.SetShowLineNumbers(true)
+	p.view.SetMonospace(true)
+
+	click := gtk.NewGestureClick()
+	click.ConnectReleased(func(nPress int, x, y float64) {
+		if nPress != 1 || p.buffer.HasSelection() {
+			return
+		}
+		bx, by := p.view.WindowToBufferCoords(gtk.TextWindowWidget, int(x), int(y))
+		if iter, ok := p.view.IterAtLocation(bx, by); ok {
+			p.toggleFields(iter)
+		}
+	})
+	p.view.AddController(click)
+
+	searchBar := p.createSearchBar()
+	searchBar.SetKeyCaptureWidget(box)
+	box.Append(searchBar)
+	searchToggle := gtk.NewToggleButton()
+	searchToggle.SetIconName("edit-find-symbolic")
+	searchToggle.SetTooltipText("Search")
+	searchToggle.ConnectToggled(func() {
+		searchBar.SetSearchMode(searchToggle.Active())
+	})
+	searchBar.NotifyProperty("search-mode-enabled", func() {
+		searchToggle.SetActive(searchBar.SearchMode())
+	})
+	header.PackStart(searchToggle)
 
 	scrolledWindow := gtk.NewScrolledWindow()
-	scrolledWindow.SetChild(view)
+	scrolledWindow.SetChild(p.view)
 	scrolledWindow.SetVExpand(true)
 	box.Append(scrolledWindow)
 
-	logs, err := podLogs(ctx, cluster, pod, container)
-	if err != nil {
-		ShowErrorDialog(ctx, "Could not load logs", err)
+	// Stop following the end of the log as soon as the user scrolls up, and
+	// resume once they scroll back down.
+	adj := scrolledWindow.VAdjustment()
+	adj.ConnectValueChanged(func() {
+		p.autoscroll = adj.Value()+adj.PageSize() >= adj.Upper()-1
+	})
+
+	return &p
+}
+
+func (p *LogPage) createSearchBar() *gtk.SearchBar {
+	settings := gtksource.NewSearchSettings()
+	settings.SetWrapAround(true)
+	p.search = gtksource.NewSearchContext(p.buffer, settings)
+	p.search.SetHighlight(true)
+
+	box := gtk.NewBox(gtk.OrientationHorizontal, 6)
+
+	entry := gtk.NewSearchEntry()
+	entry.SetHExpand(true)
+	box.Append(entry)
+
+	nav := gtk.NewBox(gtk.OrientationHorizontal, 0)
+	nav.AddCSSClass("linked")
+	previous := gtk.NewButton()
+	previous.SetIconName("go-up-symbolic")
+	previous.SetTooltipText("Previous match")
+	previous.ConnectClicked(func() { p.findMatch(false) })
+	nav.Append(previous)
+	next := gtk.NewButton()
+	next.SetIconName("go-down-symbolic")
+	next.SetTooltipText("Next match")
+	next.ConnectClicked(func() { p.findMatch(true) })
+	nav.Append(next)
+	box.Append(nav)
+
+	regex := gtk.NewToggleButtonWithLabel(".*")
+	regex.SetTooltipText("Regular expression")
+	box.Append(regex)
+
+	filter := gtk.NewToggleButton()
+	filter.SetIconName("funnel-symbolic")
+	filter.SetTooltipText("Show only matching lines")
+	box.Append(filter)
+
+	update := func() {
+		text := entry.Text()
+		settings.SetRegexEnabled(regex.Active())
+		settings.SetSearchText(text)
+
+		entry.RemoveCSSClass("error")
+		match, err := newLineMatcher(text, regex.Active())
+		if err != nil {
+			entry.AddCSSClass("error")
+			return
+		}
+		if !filter.Active() {
+			match = nil
+		}
+		if match != nil || p.match != nil {
+			p.match = match
+			p.render()
+		}
+	}
+	entry.ConnectSearchChanged(update)
+	regex.ConnectToggled(update)
+	filter.ConnectToggled(update)
+	entry.ConnectActivate(func() { p.findMatch(true) })
+	entry.ConnectNextMatch(func() { p.findMatch(true) })
+	entry.ConnectPreviousMatch(func() { p.findMatch(false) })
+
+	bar := gtk.NewSearchBar()
+	bar.SetChild(box)
+	bar.ConnectEntry(entry)
+	bar.SetShowCloseButton(true)
+	return bar
+}
+
+// findMatch selects the next or previous search match relative to the
+// current selection.
+func (p *LogPage) findMatch(forward bool) {
+	start, end, ok := p.buffer.SelectionBounds()
+	if !ok {
+		start = p.buffer.IterAtMark(p.buffer.GetInsert())
+		end = start
+	}
+
+	var matchStart, matchEnd *gtk.TextIter
+	if forward {
+		matchStart, matchEnd, _, ok = p.search.Forward(end)
 	} else {
-		if text, err := ansi.Parse(string(logs)); err != nil {
-			buffer.SetText(string(logs))
-		} else {
-			for _, text := range text {
-				var attr []string
-				if text.FgCol != nil {
-					attr = append(attr, fmt.Sprintf(`foreground="%s"`, text.FgCol.Hex))
+		matchStart, matchEnd, _, ok = p.search.Backward(start)
+	}
+	if !ok {
+		return
+	}
+	p.autoscroll = false
+	p.buffer.SelectRange(matchStart, matchEnd)
+	p.view.ScrollToIter(matchStart, 0.1, false, 0, 0)
+}
+
+func newLineMatcher(text string, regex bool) (func(string) bool, error) {
+	if text == "" {
+		return nil, nil
+	}
+	if regex {
+		re, err := regexp.Compile("(?i)" + text)
+		if err != nil {
+			return nil, err
+		}
+		return re.MatchString, nil
+	}
+	text = strings.ToLower(text)
+	return func(line string) bool {
+		return strings.Contains(strings.ToLower(line), text)
+	}, nil
+}
+
+func (p *LogPage) createOptions() *gtk.MenuButton {
+	group := adw.NewPreferencesGroup()
+	group.SetSizeRequest(360, -1)
+
+	previous := adw.NewSwitchRow()
+	previous.SetTitle("Previous container")
+	previous.SetSubtitle("Logs of the last terminated instance")
+	group.Add(previous)
+
+	timestamps := adw.NewSwitchRow()
+	timestamps.SetTitle("Timestamps")
+	group.Add(timestamps)
+
+	since := adw.NewEntryRow()
+	since.SetTitle("Since (e.g. 15m or 2006-01-02T15:04:05Z)")
+	since.SetShowApplyButton(true)
+	group.Add(since)
+
+	tail := adw.NewSpinRowWithRange(0, math.MaxInt32, 100)
+	tail.SetTitle("Tail lines")
+	tail.SetSubtitle("0 shows all lines")
+	group.Add(tail)
+
+	limit := adw.NewSpinRowWithRange(0, 1024, 1)
+	limit.SetTitle("Limit (MiB)")
+	limit.SetSubtitle("0 is unlimited")
+	group.Add(limit)
+
+	maxLines := adw.NewSpinRowWithRange(1000, 1000000, 1000)
+	maxLines.SetTitle("Maximum lines")
+	maxLines.SetSubtitle("Older lines are discarded")
+	maxLines.SetValue(float64(p.maxLines))
+	group.Add(maxLines)
+
+	fields := adw.NewSwitchRow()
+	fields.SetTitle("Expand JSON fields")
+	fields.SetSubtitle("Click a structured log line to toggle its fields")
+	fields.SetActive(p.expandFields)
+	fields.NotifyProperty("active", func() {
+		p.expandFields = fields.Active()
+		p.render()
+	})
+	group.Add(fields)
+
+	update := func() {
+		opts := corev1.PodLogOptions{
+			Previous:   previous.Active(),
+			Timestamps: timestamps.Active(),
+		}
+		if lines := int64(tail.Value()); lines > 0 {
+			opts.TailLines = &lines
+		}
+		if mib := int64(limit.Value()); mib > 0 {
+			bytes := mib * 1024 * 1024
+			opts.LimitBytes = &bytes
+		}
+		since.RemoveCSSClass("error")
+		if text := strings.TrimSpace(since.Text()); text != "" {
+			if d, err := time.ParseDuration(text); err == nil {
+				seconds := int64(d.Seconds())
+				opts.SinceSeconds = &seconds
+			} else if t, err := time.Parse(time.RFC3339, text); err == nil {
+				opts.SinceTime = &metav1.Time{Time: t}
+			} else {
+				since.AddCSSClass("error")
+				return
+			}
+		}
+		p.opts = opts
+		p.maxLines = int(maxLines.Value())
+		p.reload()
+	}
+	previous.NotifyProperty("active", update)
+	timestamps.NotifyProperty("active", update)
+	since.ConnectApply(update)
+	// Spin rows notify on each step while held or typed into
+	updateLater, _ := debounce.Debounce(func() {
+		glib.IdleAdd(update)
+	}, 500*time.Millisecond)
+	tail.NotifyProperty("value", updateLater)
+	limit.NotifyProperty("value", updateLater)
+	maxLines.NotifyProperty("value", updateLater)
+
+	popover := gtk.NewPopover()
+	popover.SetChild(group)
+	button := gtk.NewMenuButton()
+	button.SetIconName("controls-symbolic")
+	button.SetTooltipText("Options")
+	button.SetPopover(popover)
+	return button
+}
+
+// reload cancels the current log stream, clears the buffer and queries the
+// logs again with the current options.
+func (p *LogPage) reload() {
+	if p.cancel != nil {
+		p.cancel()
+	}
+	ctx, cancel := context.WithCancel(p.ctx)
+	p.cancel = cancel
+
+	p.mutex.Lock()
+	p.generation++
+	p.pending = newRing[logLine](p.maxLines)
+	p.following = map[string]bool{}
+	p.sources = nil
+	generation := p.generation
+	p.mutex.Unlock()
+
+	p.lines = newRing[logLine](p.maxLines)
+	p.shown = newRing[int](p.maxLines)
+	p.rendering = nil
+	p.buffer.SetText("")
+	p.autoscroll = true
+
+	opts := p.opts
+	if p.filter == nil {
+		source := &logSource{pod: p.pod, container: p.container}
+		p.sources = []*logSource{source}
+		go p.follow(ctx, generation, source, opts)
+		return
+	}
+
+	// The informer replays existing pods as additions, so this covers both the
+	// initial and later pods.
+	handler := func(obj interface{}) {
+		if pod, ok := obj.(*corev1.Pod); ok && p.filter(pod) {
+			p.followPod(ctx, generation, pod, opts)
+		}
+	}
+	if err := p.cluster.AddInformerEventHandler(ctx, corev1.SchemeGroupVersion.WithResource("pods"), cache.ResourceEventHandlerFuncs{
+		AddFunc: handler,
+		UpdateFunc: func(_, obj interface{}) {
+			handler(obj)
+		},
+	}); err != nil {
+		p.note(generation, &logSource{}, fmt.Sprintf("could not watch pods: %s", err))
+	}
+}
+
+// followPod starts following all containers of the pod that have logs and
+// aren't followed yet.
+func (p *LogPage) followPod(ctx context.Context, generation int, pod *corev1.Pod, opts corev1.PodLogOptions) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if generation != p.generation {
+		return
+	}
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.State.Running == nil && status.State.Terminated == nil && status.LastTerminationState.Terminated == nil {
+			continue
+		}
+		key := fmt.Sprintf("%s/%s", pod.UID, status.Name)
+		if p.following[key] {
+			continue
+		}
+		p.following[key] = true
+		source := &logSource{
+			pod:       pod,
+			container: status.Name,
+			prefix:    fmt.Sprintf("%s %s", pod.Name, status.Name),
+		}
+		hash := fnv.New32a()
+		hash.Write([]byte(source.prefix))
+		source.color = logPrefixColors[hash.Sum32()%uint32(len(logPrefixColors))]
+		p.sources = append(p.sources, source)
+		go p.follow(ctx, generation, source, opts)
+	}
+}
+
+// follow streams the container logs into the buffer. When the stream ends, it
+// waits for the container to run again and reconnects.
+func (p *LogPage) follow(ctx context.Context, generation int, source *logSource, opts corev1.PodLogOptions) {
+	restarts := containerRestarts(source.pod, source.container)
+	opts.Container = source.container
+	// Previous instances are terminated, there is nothing to follow
+	opts.Follow = !opts.Previous
+	// Server timestamps tell where to resume, they're removed again unless shown
+	timestamps := opts.Timestamps
+	opts.Timestamps = true
+	var last time.Time
+
+	updates := make(chan *corev1.Pod, 1)
+	err := p.cluster.AddInformerEventHandler(ctx, corev1.SchemeGroupVersion.WithResource("pods"), cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(_, obj interface{}) {
+			pod, ok := obj.(*corev1.Pod)
+			if !ok || pod.UID != source.pod.UID {
+				return
+			}
+			select {
+			case <-updates:
+			default:
+			}
+			updates <- pod
+		},
+	})
+	if err != nil {
+		// Restarts are still noticed by polling the pod, just later
+		p.note(generation, source, fmt.Sprintf("could not watch pod: %s", err))
+	}
+
+	for first := true; ; first = false {
+		var err error
+		last, err = p.stream(ctx, generation, source, &opts, last, timestamps)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil && first {
+			if p.filter != nil {
+				p.note(generation, source, err.Error())
+			} else {
+				glib.IdleAdd(func() {
+					ShowErrorDialog(p.ctx, "Could not load logs", err)
+				})
+			}
+			return
+		}
+		if opts.Previous {
+			return
+		}
+
+		pod, err := p.cluster.CoreV1().Pods(source.pod.Namespace).Get(ctx, source.pod.Name, metav1.GetOptions{})
+		for {
+			if apierrors.IsNotFound(err) {
+				p.note(generation, source, "pod was deleted")
+				return
+			}
+			if pod != nil {
+				if status := containerStatus(pod, source.container); status != nil && status.State.Running != nil {
+					if status.RestartCount != restarts {
+						restarts = status.RestartCount
+						opts.SinceSeconds = nil
+						opts.SinceTime = nil
+						last = time.Time{}
+						p.note(generation, source, "container restarted")
+						break
+					} else {
+						// The stream was interrupted while the container kept running.
+						// SinceTime has second precision, lines up to the last one
+						// received are skipped.
+						if !last.IsZero() {
+							opts.SinceSeconds = nil
+							opts.SinceTime = &metav1.Time{Time: last}
+							opts.TailLines = nil
+						}
+						select {
+						case <-ctx.Done():
+							return
+						case <-time.After(time.Second):
+						}
+						break
+					}
 				}
-				if text.BgCol != nil {
-					attr = append(attr, fmt.Sprintf(`background="%s"`, text.BgCol.Hex))
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case pod = <-updates:
+				err = nil
+			case <-time.After(time.Minute):
+				pod, err = p.cluster.CoreV1().Pods(source.pod.Namespace).Get(ctx, source.pod.Name, metav1.GetOptions{})
+			}
+		}
+	}
+}
+
+// stream reads the log stream line by line until it ends and returns the
+// server timestamp of the last line. Lines up to after are skipped, and
+// timestamps are removed unless they should be shown.
+func (p *LogPage) stream(ctx context.Context, generation int, source *logSource, opts *corev1.PodLogOptions, after time.Time, timestamps bool) (time.Time, error) {
+	last := after
+	req := p.cluster.CoreV1().Pods(source.pod.Namespace).GetLogs(source.pod.Name, opts)
+	r, err := req.Stream(ctx)
+	if err != nil {
+		return last, err
+	}
+	defer r.Close()
+
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadString('\n')
+		if len(line) > 0 {
+			if t, rest, ok := splitLogTimestamp(line); ok {
+				if !t.After(after) {
+					continue
+				}
+				last = t
+				if !timestamps {
+					line = rest
 				}
-				buffer.InsertMarkup(buffer.EndIter(), fmt.Sprintf(`<span %s>%s</span>`, strings.Join(attr, " "), html.EscapeString(text.Label)))
 			}
+			p.push(logLine{generation: generation, source: source, text: line})
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return last, nil
+			}
+			return last, err
 		}
 	}
+}
 
-	return &p
+// splitLogTimestamp splits the server timestamp added by the Timestamps log
+// option off the line.
+func splitLogTimestamp(line string) (time.Time, string, bool) {
+	ts, rest, ok := strings.Cut(line, " ")
+	if !ok {
+		return time.Time{}, line, false
+	}
+	t, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return time.Time{}, line, false
+	}
+	return t, rest, true
 }
 
-func podLogs(ctx context.Context, cluster *api.Cluster, pod *corev1.Pod, container string) ([]byte, error) {
-	req := cluster.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{Container: container})
-	r, err := req.Stream(ctx)
+func (p *LogPage) push(line logLine) {
+	prepareLogLine(&line)
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if line.generation != p.generation {
+		return
+	}
+	p.pending.Push(line)
+	if !p.scheduled {
+		p.scheduled = true
+		glib.IdleAdd(p.flush)
+	}
+}
+
+func (p *LogPage) note(generation int, source *logSource, text string) {
+	p.push(logLine{generation: generation, source: source, text: text, note: true})
+}
+
+// flush appends a batch of pending lines to the buffer and schedules itself
+// again if more are left. Lines are held back while the page is paused.
+func (p *LogPage) flush() {
+	p.mutex.Lock()
+	if p.paused {
+		p.scheduled = false
+		p.mutex.Unlock()
+		return
+	}
+	var lines []logLine
+	for len(lines) < logBatchSize {
+		line, ok := p.pending.Pop()
+		if !ok {
+			break
+		}
+		lines = append(lines, line)
+	}
+	if p.pending.Len() > 0 {
+		glib.IdleAdd(p.flush)
+	} else {
+		p.scheduled = false
+	}
+	p.mutex.Unlock()
+
+	for _, line := range lines {
+		p.lines.Push(line)
+	}
+	// New lines go after the ones still being rendered
+	if p.rendering != nil {
+		p.rendering = append(p.rendering, lines...)
+		return
+	}
+	p.insert(lines)
+}
+
+// render replaces the buffer contents with all retained lines, e.g. after the
+// filter changed. Lines are inserted in batches like new lines.
+func (p *LogPage) render() {
+	p.buffer.SetText("")
+	p.shown = newRing[int](p.maxLines)
+	scheduled := p.rendering != nil
+	p.rendering = nil
+	p.lines.Each(func(line logLine) {
+		p.rendering = append(p.rendering, line)
+	})
+	if p.rendering != nil && !scheduled {
+		glib.IdleAdd(p.renderBatch)
+	}
+}
+
+func (p *LogPage) renderBatch() {
+	if p.rendering == nil {
+		return
+	}
+	n := min(logBatchSize, len(p.rendering))
+	p.insert(p.rendering[:n])
+	if n == len(p.rendering) {
+		p.rendering = nil
+		return
+	}
+	p.rendering = p.rendering[n:]
+	glib.IdleAdd(p.renderBatch)
+}
+
+// insert appends the lines that pass the filter to the buffer and drops the
+// oldest lines beyond the limit.
+func (p *LogPage) insert(lines []logLine) {
+	var markup strings.Builder
+	writeMarkup := func() {
+		if markup.Len() > 0 {
+			p.buffer.InsertMarkup(p.buffer.EndIter(), markup.String())
+			markup.Reset()
+		}
+	}
+
+	trim := 0
+	for _, line := range lines {
+		if p.match != nil && !line.note && !p.match(line.plain) {
+			continue
+		}
+		markup.WriteString(line.markup)
+		if line.fields != "" {
+			writeMarkup()
+			offset := p.buffer.CharCount()
+			p.buffer.Insert(p.buffer.EndIter(), line.fields)
+			tag := "fields-collapsed"
+			if p.expandFields {
+				tag = "fields"
+			}
+			p.buffer.ApplyTagByName(tag, p.buffer.IterAtOffset(offset), p.buffer.EndIter())
+		}
+		if rows, ok := p.shown.Push(line.rows); ok {
+			trim += rows
+		}
+	}
+	writeMarkup()
+
+	if trim > 0 {
+		end, _ := p.buffer.IterAtLine(trim)
+		p.buffer.Delete(p.buffer.StartIter(), end)
+	}
+
+	if p.autoscroll {
+		p.view.ScrollToMark(p.endMark, 0, false, 0, 0)
+	}
+}
+
+// toggleFields expands or collapses the fields of the structured line at the
+// iter, which is either on the first row of the line or on one of its fields.
+func (p *LogPage) toggleFields(iter *gtk.TextIter) {
+	from := p.buffer.TagTable().Lookup("fields")
+	to := p.buffer.TagTable().Lookup("fields-collapsed")
+	start := iter.Copy()
+	start.SetLineOffset(0)
+	if start.HasTag(from) {
+		if !start.StartsTag(from) {
+			start.BackwardToTagToggle(from)
+		}
+	} else {
+		// Fields follow the first row of the line
+		if !start.ForwardLine() {
+			return
+		}
+		if start.HasTag(to) {
+			from, to = to, from
+		} else if !start.HasTag(from) {
+			return
+		}
+	}
+	end := start.Copy()
+	end.ForwardToTagToggle(from)
+	p.buffer.RemoveTag(from, start, end)
+	p.buffer.ApplyTag(to, start, end)
+}
+
+// prepareLogLine renders the line to markup. It's called from the streaming
+// goroutines to keep parsing off the main loop.
+func prepareLogLine(line *logLine) {
+	text := line.text
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	var markup strings.Builder
+	if prefix := line.source.prefix; prefix != "" {
+		fmt.Fprintf(&markup, `<span foreground="%s"><b>%s</b></span> `, line.source.color, html.EscapeString(prefix))
+	}
+
+	plain, err := ansi.Cleanse(text)
 	if err != nil {
-		return nil, err
+		plain = text
+	}
+	// Whitespace is kept as received, only the line break is dropped
+	line.plain = strings.TrimSuffix(plain, "\n")
+	if prefix := line.source.prefix; prefix != "" {
+		line.plain = prefix + " " + line.plain
+	}
+
+	switch entry := parseJSONLog(text); {
+	case line.note:
+		fmt.Fprintf(&markup, `<span fgalpha="50%%"><i>%s</i></span>`, html.EscapeString(text))
+	case entry != nil:
+		markup.WriteString(entry.markup())
+		if len(entry.fields) > 0 {
+			line.fields = strings.Join(entry.fields, "\n") + "\n"
+		}
+	default:
+		markup.WriteString(ansiMarkup(text))
+	}
+	line.markup = markup.String()
+	// Messages, field values and notes may span several rows
+	line.rows = strings.Count(line.markup+line.fields, "\n")
+}
+
+func ansiMarkup(str string) string {
+	text, err := ansi.Parse(str)
+	if err != nil {
+		return html.EscapeString(str)
+	}
+	var markup strings.Builder
+	for _, text := range text {
+		var attr []string
+		if text.FgCol != nil {
+			attr = append(attr, fmt.Sprintf(`foreground="%s"`, text.FgCol.Hex))
+		}
+		if text.BgCol != nil {
+			attr = append(attr, fmt.Sprintf(`background="%s"`, text.BgCol.Hex))
+		}
+		fmt.Fprintf(&markup, `<span %s>%s</span>`, strings.Join(attr, " "), html.EscapeString(text.Label))
+	}
+	return markup.String()
+}
+
+func containerStatus(pod *corev1.Pod, container string) *corev1.ContainerStatus {
+	for _, s := range pod.Status.ContainerStatuses {
+		if s.Name == container {
+			return &s
+		}
+	}
+	return nil
+}
+
+func containerRestarts(pod *corev1.Pod, container string) int32 {
+	if status := containerStatus(pod, container); status != nil {
+		return status.RestartCount
+	}
+	return 0
+}
+
+// jsonLog is a structured log line split into its well-known parts.
+type jsonLog struct {
+	prefix  string
+	time    string
+	level   string
+	message string
+	fields  []string
+}
+
+var (
+	jsonTimeKeys    = []string{"time", "ts", "timestamp", "@timestamp"}
+	jsonLevelKeys   = []string{"level", "lvl", "severity", "log.level"}
+	jsonMessageKeys = []string{"msg", "message"}
+)
+
+// parseJSONLog returns nil if the line isn't a JSON object with a level or
+// message. A leading timestamp added by the log options is kept as prefix.
+func parseJSONLog(line string) *jsonLog {
+	i := strings.IndexByte(line, '{')
+	if i < 0 || strings.Contains(strings.TrimSpace(line[:i]), " ") {
+		return nil
+	}
+
+	var object map[string]any
+	if err := json.Unmarshal([]byte(line[i:]), &object); err != nil {
+		return nil
+	}
+
+	entry := jsonLog{prefix: strings.TrimSpace(line[:i])}
+	take := func(keys []string) string {
+		for _, key := range keys {
+			if value, ok := object[key]; ok {
+				delete(object, key)
+				return jsonLogValue(value)
+			}
+		}
+		return ""
+	}
+	entry.time = take(jsonTimeKeys)
+	entry.level = take(jsonLevelKeys)
+	entry.message = take(jsonMessageKeys)
+	if entry.level == "" && entry.message == "" {
+		return nil
+	}
+
+	for key, value := range object {
+		entry.fields = append(entry.fields, fmt.Sprintf("%s=%s", key, jsonLogValue(value)))
+	}
+	slices.Sort(entry.fields)
+
+	return &entry
+}
+
+// markup renders the first row of the entry, the fields are shown separately.
+func (entry *jsonLog) markup() string {
+	var markup []string
+	if entry.prefix != "" {
+		markup = append(markup, html.EscapeString(entry.prefix))
+	}
+	if entry.time != "" {
+		markup = append(markup, fmt.Sprintf(`<span fgalpha="50%%">%s</span>`, html.EscapeString(entry.time)))
+	}
+	if entry.level != "" {
+		markup = append(markup, fmt.Sprintf(`<span foreground="%s"><b>%s</b></span>`, levelColor(entry.level), html.EscapeString(strings.ToUpper(entry.level))))
+	}
+	markup = append(markup, html.EscapeString(entry.message))
+	return strings.Join(markup, " ") + "\n"
+}
+
+func jsonLogValue(value any) string {
+	switch value := value.(type) {
+	case string:
+		return value
+	default:
+		data, _ := json.Marshal(value)
+		return string(data)
+	}
+}
+
+func levelColor(level string) string {
+	switch strings.ToLower(level) {
+	case "error", "err", "fatal", "panic", "critical", "crit", "dpanic":
+		return "#c01c28"
+	case "warn", "warning":
+		return "#e66100"
+	case "info", "notice":
+		return "#1c71d8"
+	default:
+		return "#77767b"
 	}
-	return io.ReadAll(r)
 }
@@ -0,0 +1,167 @@
+package widget
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseJSONLog(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want *jsonLog
+	}{
+		{
+			name: "plain text",
+			line: "starting server\n",
+		},
+		{
+			name: "invalid json",
+			line: `{"level": "info"` + "\n",
+		},
+		{
+			name: "no level or message",
+			line: `{"status": 200}` + "\n",
+		},
+		{
+			name: "text before object",
+			line: `server said {"msg": "hello"}` + "\n",
+		},
+		{
+			name: "well-known keys",
+			line: `{"ts": "2024-01-01T00:00:00Z", "level": "info", "msg": "listening", "port": 8080, "tls": true, "addr": "0.0.0.0"}` + "\n",
+			want: &jsonLog{
+				time:    "2024-01-01T00:00:00Z",
+				level:   "info",
+				message: "listening",
+				fields:  []string{"addr=0.0.0.0", "port=8080", "tls=true"},
+			},
+		},
+		{
+			name: "alternative keys",
+			line: `{"@timestamp": "now", "severity": "ERROR", "message": "failed", "error": {"code": 1}}` + "\n",
+			want: &jsonLog{
+				time:    "now",
+				level:   "ERROR",
+				message: "failed",
+				fields:  []string{`error={"code":1}`},
+			},
+		},
+		{
+			name: "timestamp prefix",
+			line: `2024-01-01T00:00:00.000000000Z {"level": "warn"}` + "\n",
+			want: &jsonLog{
+				prefix: "2024-01-01T00:00:00.000000000Z",
+				level:  "warn",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseJSONLog(tt.line); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseJSONLog() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewLineMatcher(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		regex   bool
+		line    string
+		want    bool
+		wantErr bool
+	}{
+		{name: "substring", text: "error", line: "an error occurred", want: true},
+		{name: "case insensitive", text: "ERROR", line: "an error occurred", want: true},
+		{name: "no match", text: "warning", line: "an error occurred", want: false},
+		{name: "substring ignores regex syntax", text: "a.c", line: "abc", want: false},
+		{name: "regex", text: "^an? err", regex: true, line: "an error occurred", want: true},
+		{name: "regex case insensitive", text: "ERR(OR)?", regex: true, line: "an error occurred", want: true},
+		{name: "regex no match", text: "^error", regex: true, line: "an error occurred", want: false},
+		{name: "invalid regex", text: "(", regex: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, err := newLineMatcher(tt.text, tt.regex)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newLineMatcher() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := match(tt.line); got != tt.want {
+				t.Errorf("match(%q) = %v, want %v", tt.line, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("empty", func(t *testing.T) {
+		match, err := newLineMatcher("", true)
+		if err != nil || match != nil {
+			t.Errorf("newLineMatcher() matcher = %v, err = %v, want neither", match != nil, err)
+		}
+	})
+}
+
+func TestPrepareLogLinePlain(t *testing.T) {
+	tests := []struct {
+		name   string
+		prefix string
+		text   string
+		want   string
+	}{
+		{name: "line break dropped", text: "started\n", want: "started"},
+		{name: "whitespace kept", text: "  indented\t\n", want: "  indented\t"},
+		{name: "ansi removed", text: "\x1b[31merror\x1b[0m\n", want: "error"},
+		{name: "prefix", prefix: "web/app", text: " started\n", want: "web/app  started"},
+		{name: "empty", text: "\n", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line := logLine{source: &logSource{prefix: tt.prefix}, text: tt.text}
+			prepareLogLine(&line)
+			if line.plain != tt.want {
+				t.Errorf("plain = %q, want %q", line.plain, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitLogTimestamp(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		wantTime time.Time
+		wantRest string
+		wantOk   bool
+	}{
+		{
+			name:     "timestamp",
+			line:     "2024-01-01T10:00:00.123456789Z started\n",
+			wantTime: time.Date(2024, 1, 1, 10, 0, 0, 123456789, time.UTC),
+			wantRest: "started\n",
+			wantOk:   true,
+		},
+		{
+			name:     "whitespace kept",
+			line:     "2024-01-01T10:00:00Z   indented\n",
+			wantTime: time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC),
+			wantRest: "  indented\n",
+			wantOk:   true,
+		},
+		{name: "no timestamp", line: "started at 10:00\n", wantRest: "started at 10:00\n"},
+		{name: "no space", line: "2024-01-01T10:00:00Z", wantRest: "2024-01-01T10:00:00Z"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, rest, ok := splitLogTimestamp(tt.line)
+			if !got.Equal(tt.wantTime) || rest != tt.wantRest || ok != tt.wantOk {
+				t.Errorf("splitLogTimestamp() = %v, %q, %v, want %v, %q, %v", got, rest, ok, tt.wantTime, tt.wantRest, tt.wantOk)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package widget
+
+// ring is a FIFO queue that holds at most size items. Pushing to a full ring
+// evicts the oldest item. Storage grows on demand up to size.
+type ring[T any] struct {
+	size  int
+	items []T
+	start int
+	count int
+}
+
+func newRing[T any](size int) *ring[T] {
+	return &ring[T]{size: max(size, 1)}
+}
+
+func (r *ring[T]) Len() int {
+	return r.count
+}
+
+// Push appends v and returns the evicted item, if any.
+func (r *ring[T]) Push(v T) (evicted T, ok bool) {
+	if r.count == len(r.items) && len(r.items) < r.size {
+		items := make([]T, min(r.size, max(16, 2*len(r.items))))
+		for i := 0; i < r.count; i++ {
+			items[i] = r.items[(r.start+i)%len(r.items)]
+		}
+		r.items = items
+		r.start = 0
+	}
+	if r.count == len(r.items) {
+		evicted = r.items[r.start]
+		r.items[r.start] = v
+		r.start = (r.start + 1) % len(r.items)
+		return evicted, true
+	}
+	r.items[(r.start+r.count)%len(r.items)] = v
+	r.count++
+	return evicted, false
+}
+
+// Pop removes and returns the oldest item.
+func (r *ring[T]) Pop() (v T, ok bool) {
+	if r.count == 0 {
+		return v, false
+	}
+	var zero T
+	v = r.items[r.start]
+	r.items[r.start] = zero
+	r.start = (r.start + 1) % len(r.items)
+	r.count--
+	return v, true
+}
+
+func (r *ring[T]) Each(fn func(T)) {
+	for i := 0; i < r.count; i++ {
+		fn(r.items[(r.start+i)%len(r.items)])
+	}
+}
@@ -0,0 +1,107 @@
+package widget
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRing(t *testing.T) {
+	tests := []struct {
+		name        string
+		size        int
+		push        []int
+		pop         int
+		wantEvicted []int
+		wantPopped  []int
+		want        []int
+	}{
+		{
+			name: "empty",
+			size: 3,
+		},
+		{
+			name: "below size",
+			size: 3,
+			push: []int{1, 2},
+			want: []int{1, 2},
+		},
+		{
+			name:        "evicts oldest",
+			size:        3,
+			push:        []int{1, 2, 3, 4, 5},
+			wantEvicted: []int{1, 2},
+			want:        []int{3, 4, 5},
+		},
+		{
+			name:        "grows beyond initial storage",
+			size:        40,
+			push:        seq(50),
+			wantEvicted: seq(10),
+			want:        seq(50)[10:],
+		},
+		{
+			name:        "pop",
+			size:        3,
+			push:        []int{1, 2, 3, 4},
+			pop:         2,
+			wantEvicted: []int{1},
+			wantPopped:  []int{2, 3},
+			want:        []int{4},
+		},
+		{
+			name:       "pop more than held",
+			size:       3,
+			push:       []int{1},
+			pop:        2,
+			wantPopped: []int{1},
+		},
+		{
+			name:        "minimum size",
+			size:        0,
+			push:        []int{1, 2},
+			wantEvicted: []int{1},
+			want:        []int{2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newRing[int](tt.size)
+			var evicted []int
+			for _, v := range tt.push {
+				if e, ok := r.Push(v); ok {
+					evicted = append(evicted, e)
+				}
+			}
+			var popped []int
+			for i := 0; i < tt.pop; i++ {
+				if v, ok := r.Pop(); ok {
+					popped = append(popped, v)
+				}
+			}
+			var items []int
+			r.Each(func(v int) {
+				items = append(items, v)
+			})
+			if !reflect.DeepEqual(evicted, tt.wantEvicted) {
+				t.Errorf("evicted = %v, want %v", evicted, tt.wantEvicted)
+			}
+			if !reflect.DeepEqual(popped, tt.wantPopped) {
+				t.Errorf("popped = %v, want %v", popped, tt.wantPopped)
+			}
+			if !reflect.DeepEqual(items, tt.want) {
+				t.Errorf("items = %v, want %v", items, tt.want)
+			}
+			if r.Len() != len(tt.want) {
+				t.Errorf("Len() = %d, want %d", r.Len(), len(tt.want))
+			}
+		})
+	}
+}
+
+func seq(n int) []int {
+	s := make([]int, n)
+	for i := range s {
+		s[i] = i
+	}
+	return s
+}
@@ -0,0 +1,265 @@
+package widget
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/gio/v2"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/getseabird/seabird/internal/ctxt"
+	"github.com/leaanthony/go-ansi-parser"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func (p *LogPage) createSaveMenu() *gtk.MenuButton {
+	group := gio.NewSimpleActionGroup()
+	addAction := func(name string, fn func()) {
+		action := gio.NewSimpleAction(name, nil)
+		action.ConnectActivate(func(_ *glib.Variant) { fn() })
+		group.AddAction(action)
+	}
+	addAction("save-shown", func() { p.saveShown(false) })
+	addAction("save-shown-raw", func() { p.saveShown(true) })
+	addAction("save-full", func() { p.saveFull(false) })
+	addAction("save-full-raw", func() { p.saveFull(true) })
+	addAction("save-archive", p.saveArchive)
+	p.InsertActionGroup("log", group)
+
+	shown := gio.NewMenu()
+	shown.Append("As Text…", "log.save-shown")
+	shown.Append("Raw…", "log.save-shown-raw")
+	full := gio.NewMenu()
+	full.Append("As Text…", "log.save-full")
+	full.Append("Raw…", "log.save-full-raw")
+	archive := gio.NewMenu()
+	archive.Append("All Containers (.tar.gz)…", "log.save-archive")
+	model := gio.NewMenu()
+	model.AppendSection("Shown Lines", shown)
+	model.AppendSection("Full Log", full)
+	model.AppendSection("", archive)
+
+	button := gtk.NewMenuButton()
+	button.SetIconName("document-save-symbolic")
+	button.SetTooltipText("Save logs…")
+	button.SetMenuModel(model)
+	return button
+}
+
+// saveShown writes the lines that are currently shown. Text lines are written
+// without ANSI escape codes, raw lines are written as received and without
+// notes.
+func (p *LogPage) saveShown(raw bool) {
+	var b strings.Builder
+	p.lines.Each(func(line logLine) {
+		if p.match != nil && !line.note && !p.match(line.plain) {
+			return
+		}
+		if !raw {
+			b.WriteString(line.plain + "\n")
+			return
+		}
+		if line.note {
+			return
+		}
+		if line.source.prefix != "" {
+			b.WriteString(line.source.prefix + " ")
+		}
+		b.WriteString(line.text)
+	})
+	data := b.String()
+
+	p.saveFile(p.fileName()+".log", func(w io.Writer) error {
+		_, err := io.WriteString(w, data)
+		return err
+	})
+}
+
+// saveFull fetches the complete logs of all sources with the current options
+// and writes them one after another.
+func (p *LogPage) saveFull(raw bool) {
+	sources := p.logSources()
+	opts := p.opts
+	p.saveFile(p.fileName()+".log", func(w io.Writer) error {
+		for _, source := range sources {
+			if err := p.writeLogs(w, source, opts, raw); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// saveArchive fetches the logs of all containers, or all containers of the
+// matching pods on aggregated pages, into a .tar.gz archive.
+func (p *LogPage) saveArchive() {
+	sources := p.logSources()
+	if p.filter == nil {
+		sources = nil
+		for _, c := range slices.Concat(p.pod.Spec.InitContainers, p.pod.Spec.Containers) {
+			sources = append(sources, &logSource{pod: p.pod, container: c.Name})
+		}
+		for _, c := range p.pod.Spec.EphemeralContainers {
+			sources = append(sources, &logSource{pod: p.pod, container: c.Name})
+		}
+	}
+	opts := p.opts
+
+	p.saveFile(p.fileName()+".tar.gz", func(w io.Writer) error {
+		gz := gzip.NewWriter(w)
+		tw := tar.NewWriter(gz)
+		for _, source := range sources {
+			if err := p.writeArchiveEntry(tw, &logSource{pod: source.pod, container: source.container}, opts); err != nil {
+				return err
+			}
+		}
+		if err := tw.Close(); err != nil {
+			return err
+		}
+		return gz.Close()
+	})
+}
+
+// writeArchiveEntry buffers the logs in a temporary file, as the tar header
+// needs the size upfront. If the logs can't be fetched, e.g. because the
+// container didn't start yet or has no previous instance, the error is written
+// instead so the other containers are still saved.
+func (p *LogPage) writeArchiveEntry(tw *tar.Writer, source *logSource, opts corev1.PodLogOptions) error {
+	tmp, err := os.CreateTemp("", "seabird-logs-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	name := fmt.Sprintf("%s/%s", source.pod.Name, source.container)
+	if err := p.writeLogs(tmp, source, opts, true); err != nil {
+		if p.ctx.Err() != nil {
+			return err
+		}
+		note := err.Error() + "\n"
+		return writeTarEntry(tw, name+".error", strings.NewReader(note), int64(len(note)))
+	}
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return writeTarEntry(tw, name+".log", tmp, size)
+}
+
+func writeTarEntry(tw *tar.Writer, name string, r io.Reader, size int64) error {
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    size,
+		ModTime: time.Now(),
+	}); err != nil {
+		return err
+	}
+	_, err := io.Copy(tw, r)
+	return err
+}
+
+// writeLogs streams the logs of the source to w. Unless raw is set, ANSI
+// escape codes are stripped.
+func (p *LogPage) writeLogs(w io.Writer, source *logSource, opts corev1.PodLogOptions, raw bool) error {
+	opts.Container = source.container
+	opts.Follow = false
+	r, err := p.cluster.CoreV1().Pods(source.pod.Namespace).GetLogs(source.pod.Name, &opts).Stream(p.ctx)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if raw && source.prefix == "" {
+		_, err := io.Copy(w, r)
+		return err
+	}
+
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadString('\n')
+		if len(line) > 0 {
+			if !raw {
+				if plain, err := ansi.Cleanse(line); err == nil {
+					line = plain
+				}
+			}
+			if source.prefix != "" {
+				line = source.prefix + " " + line
+			}
+			if _, err := io.WriteString(w, line); err != nil {
+				return err
+			}
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// saveFile asks for a destination and writes the file in the background.
+func (p *LogPage) saveFile(name string, write func(io.Writer) error) {
+	chooser := gtk.NewFileChooserNative("Save logs", ctxt.MustFrom[*gtk.Window](p.ctx), gtk.FileChooserActionSave, "Save", "Cancel")
+	chooser.SetCurrentName(name)
+	defer chooser.Show()
+	chooser.ConnectResponse(func(responseId int) {
+		if responseId != int(gtk.ResponseAccept) {
+			return
+		}
+		path := chooser.File().Path()
+		go func() {
+			err := writeFile(path, write)
+			glib.IdleAdd(func() {
+				if err != nil {
+					ShowErrorDialog(p.ctx, "Could not save logs", err)
+					return
+				}
+				if toast, ok := ctxt.From[*adw.ToastOverlay](p.ctx); ok {
+					toast.AddToast(adw.NewToast(fmt.Sprintf("Logs saved to %s", filepath.Base(path))))
+				}
+			})
+		}()
+	})
+}
+
+func writeFile(path string, write func(io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (p *LogPage) logSources() []*logSource {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return append([]*logSource(nil), p.sources...)
+}
+
+func (p *LogPage) fileName() string {
+	if p.filter == nil {
+		return fmt.Sprintf("%s-%s", p.pod.Name, p.container)
+	}
+	return p.Title()
+}
@@ -0,0 +1,142 @@
+package widget
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/getseabird/seabird/api"
+	"github.com/getseabird/seabird/internal/ctxt"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/ptr"
+)
+
+const (
+	nodeShellContainer = "shell"
+	// nodeShellLifetime limits how long the privileged pod exists in case it
+	// isn't deleted, e.g. because seabird crashed.
+	nodeShellLifetime = time.Hour
+)
+
+// OpenNodeShell starts a privileged pod on the node and opens a terminal that
+// runs a shell in the host's root filesystem. The pod is deleted when the
+// terminal page is hidden or the context is done.
+func OpenNodeShell(ctx context.Context, cluster *api.Cluster, node *corev1.Node, nav *adw.NavigationView) {
+	prefs := cluster.ClusterPreferences.Value().Terminal
+	pod := nodeShellPod(node, prefs.NodeShellImage, prefs.NodeShellNamespace)
+
+	if toast, ok := ctxt.From[*adw.ToastOverlay](ctx); ok {
+		toast.AddToast(adw.NewToast(fmt.Sprintf("Starting shell on %s", node.Name)))
+	}
+
+	go func() {
+		pod, err := startNodeShellPod(ctx, cluster, pod)
+		glib.IdleAdd(func() {
+			if err != nil {
+				if !errors.Is(err, context.Canceled) {
+					ShowErrorDialog(ctx, "Node shell failed", err)
+				}
+				return
+			}
+			ctx, cancel := context.WithCancel(ctx)
+			go func() {
+				<-ctx.Done()
+				deleteNodeShellPod(cluster, pod)
+			}()
+			page := NewTerminalPageWithCommand(ctx, cluster, pod, nodeShellContainer, []string{"chroot", "/host", "/bin/sh", "-l"})
+			page.ConnectHidden(cancel)
+			nav.Push(page.NavigationPage)
+		})
+	}()
+}
+
+func nodeShellPod(node *corev1.Node, image, namespace string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: fmt.Sprintf("node-shell-%s-", node.Name),
+			Namespace:    namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/managed-by": "seabird",
+			},
+		},
+		Spec: corev1.PodSpec{
+			NodeName:                      node.Name,
+			HostPID:                       true,
+			HostNetwork:                   true,
+			HostIPC:                       true,
+			RestartPolicy:                 corev1.RestartPolicyNever,
+			ActiveDeadlineSeconds:         ptr.To(int64(nodeShellLifetime.Seconds())),
+			TerminationGracePeriodSeconds: ptr.To[int64](0),
+			Tolerations: []corev1.Toleration{
+				{Operator: corev1.TolerationOpExists},
+			},
+			Containers: []corev1.Container{
+				{
+					Name:    nodeShellContainer,
+					Image:   image,
+					Command: []string{"sleep", strconv.Itoa(int(nodeShellLifetime.Seconds()))},
+					SecurityContext: &corev1.SecurityContext{
+						Privileged: ptr.To(true),
+					},
+					VolumeMounts: []corev1.VolumeMount{
+						{Name: "host-root", MountPath: "/host"},
+					},
+				},
+			},
+			Volumes: []corev1.Volume{
+				{
+					Name: "host-root",
+					VolumeSource: corev1.VolumeSource{
+						HostPath: &corev1.HostPathVolumeSource{Path: "/"},
+					},
+				},
+			},
+		},
+	}
+}
+
+// startNodeShellPod creates the pod and waits until it runs. The pod is
+// deleted again if it fails to start.
+func startNodeShellPod(ctx context.Context, cluster *api.Cluster, pod *corev1.Pod) (*corev1.Pod, error) {
+	pods := cluster.CoreV1().Pods(pod.Namespace)
+	pod, err := pods.Create(ctx, pod, metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	err = wait.PollUntilContextTimeout(ctx, time.Second, 2*time.Minute, true, func(ctx context.Context) (bool, error) {
+		p, err := pods.Get(ctx, pod.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		pod = p
+		switch pod.Status.Phase {
+		case corev1.PodRunning:
+			return true, nil
+		case corev1.PodFailed, corev1.PodSucceeded:
+			return false, fmt.Errorf("pod %s terminated: %s", pod.Name, pod.Status.Reason)
+		}
+		return false, nil
+	})
+	if err != nil {
+		go deleteNodeShellPod(cluster, pod)
+		return nil, err
+	}
+
+	return pod, nil
+}
+
+func deleteNodeShellPod(cluster *api.Cluster, pod *corev1.Pod) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := cluster.CoreV1().Pods(pod.Namespace).Delete(ctx, pod.Name, metav1.DeleteOptions{GracePeriodSeconds: ptr.To[int64](0)}); err != nil {
+		klog.Warningf("failed to delete node shell pod %s: %s", pod.Name, err)
+	}
+}
@@ -0,0 +1,46 @@
+package widget
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/getseabird/seabird/api"
+	"github.com/getseabird/seabird/internal/ctxt"
+)
+
+// NewPortForwardLink shows the local port of a forwarded remote port with a
+// health indicator. Clicking it opens the port in the browser.
+func NewPortForwardLink(ctx context.Context, fwd api.PortForward, port string) *gtk.Button {
+	url := fwd.URL(port)
+
+	box := gtk.NewBox(gtk.OrientationHorizontal, 4)
+	dot := gtk.NewImageFromIconName("big-dot-symbolic")
+	box.Append(dot)
+	box.Append(gtk.NewImageFromIconName("external-link-symbolic"))
+
+	btn := gtk.NewButton()
+	btn.SetChild(box)
+	btn.AddCSSClass("flat")
+	btn.SetVAlign(gtk.AlignCenter)
+	btn.SetSensitive(url != "")
+
+	tooltip := fmt.Sprintf("Open %s in browser", url)
+	err, probed := fwd.Health[port]
+	switch {
+	case !probed:
+		dot.AddCSSClass("dim-label")
+	case err != nil:
+		dot.AddCSSClass("error")
+		tooltip = fmt.Sprintf("%s\n%s", tooltip, err)
+	default:
+		dot.AddCSSClass("success")
+	}
+	btn.SetTooltipText(tooltip)
+
+	btn.ConnectClicked(func() {
+		gtk.ShowURI(ctxt.MustFrom[*gtk.Window](ctx), url, gdk.CURRENT_TIME)
+	})
+	return btn
+}
@@ -0,0 +1,194 @@
+package widget
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/getseabird/seabird/api"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/remotecommand"
+	"k8s.io/klog/v2"
+)
+
+// castRecorder writes the output of a terminal session to an asciicast v2
+// file. Input isn't recorded, as it may contain secrets; echoed input is part
+// of the output anyway.
+type castRecorder struct {
+	mutex   sync.Mutex
+	file    *os.File
+	start   time.Time
+	partial []byte
+}
+
+type castHeader struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Title     string            `json:"title,omitempty"`
+	Command   string            `json:"command,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+	Cluster   string            `json:"cluster"`
+	Namespace string            `json:"namespace"`
+	Pod       string            `json:"pod"`
+	Container string            `json:"container"`
+	User      string            `json:"user"`
+	LocalUser string            `json:"local_user,omitempty"`
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// startRecording creates a recording if enabled in the cluster preferences.
+// It returns nil if recording is disabled.
+func startRecording(ctx context.Context, cluster *api.Cluster, pod *corev1.Pod, container, command string, size remotecommand.TerminalSize) (*castRecorder, error) {
+	prefs := cluster.ClusterPreferences.Value()
+	if !prefs.Terminal.Record {
+		return nil, nil
+	}
+
+	dir := prefs.Terminal.RecordDirectory
+	if dir == "" {
+		dir = DefaultRecordDirectory()
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	name := fmt.Sprintf("%s_%s_%s_%s_%s.cast", start.Format("20060102T150405"), unsafeFileChars.ReplaceAllString(prefs.Name, "-"), pod.Namespace, pod.Name, container)
+	file, err := os.OpenFile(filepath.Join(dir, name), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	header := castHeader{
+		Version:   2,
+		Width:     int(size.Width),
+		Height:    int(size.Height),
+		Timestamp: start.Unix(),
+		Title:     fmt.Sprintf("%s/%s/%s", pod.Namespace, pod.Name, container),
+		Command:   command,
+		Env:       map[string]string{"TERM": "xterm-256color"},
+		Cluster:   prefs.Name,
+		Namespace: pod.Namespace,
+		Pod:       pod.Name,
+		Container: container,
+		User:      clusterUser(ctx, cluster),
+	}
+	if u, err := user.Current(); err == nil {
+		header.LocalUser = u.Username
+	}
+	if err := json.NewEncoder(file).Encode(header); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &castRecorder{file: file, start: start}, nil
+}
+
+// DefaultRecordDirectory is used when no directory is configured.
+func DefaultRecordDirectory() string {
+	cd, err := os.UserConfigDir()
+	if err != nil {
+		return "recordings"
+	}
+	return path.Join(cd, "seabird", "recordings")
+}
+
+// clusterUser asks the API server who we are authenticated as. Servers before
+// 1.28 don't support that, then the impersonated user or the user of the
+// kubeconfig context is used.
+func clusterUser(ctx context.Context, cluster *api.Cluster) string {
+	review, err := cluster.AuthenticationV1().SelfSubjectReviews().Create(ctx, &authenticationv1.SelfSubjectReview{}, metav1.CreateOptions{})
+	if err == nil && review.Status.UserInfo.Username != "" {
+		return review.Status.UserInfo.Username
+	}
+	if err != nil {
+		klog.Infof("self subject review failed: %s", err)
+	}
+	prefs := cluster.ClusterPreferences.Value()
+	if prefs.Impersonate.UserName != "" {
+		return prefs.Impersonate.UserName
+	}
+	if prefs.Kubeconfig != nil {
+		return prefs.Kubeconfig.AuthInfo
+	}
+	return ""
+}
+
+// Write records output. Incomplete UTF-8 sequences are held back until the
+// next write, as events must be valid strings. It never fails, so the
+// session isn't interrupted by recording errors.
+func (r *castRecorder) Write(p []byte) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	data := append(r.partial, p...)
+	end := len(data)
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				end = i
+			}
+			break
+		}
+	}
+	r.partial = append([]byte(nil), data[end:]...)
+	if end > 0 {
+		r.event("o", string(data[:end]))
+	}
+	return len(p), nil
+}
+
+func (r *castRecorder) resize(size remotecommand.TerminalSize) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.event("r", fmt.Sprintf("%dx%d", size.Width, size.Height))
+}
+
+func (r *castRecorder) event(kind, data string) {
+	if r.file == nil {
+		return
+	}
+	event, _ := json.Marshal([]any{time.Since(r.start).Seconds(), kind, data})
+	if _, err := r.file.Write(append(event, '\n')); err != nil {
+		klog.Warningf("failed to write recording: %s", err)
+	}
+}
+
+func (r *castRecorder) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if len(r.partial) > 0 {
+		r.event("o", string(r.partial))
+		r.partial = nil
+	}
+	file := r.file
+	r.file = nil
+	return file.Close()
+}
+
+// recordingSizeQueue records size changes passed to the remote TTY.
+type recordingSizeQueue struct {
+	remotecommand.TerminalSizeQueue
+	recorder *castRecorder
+}
+
+func (q *recordingSizeQueue) Next() *remotecommand.TerminalSize {
+	size := q.TerminalSizeQueue.Next()
+	if size != nil {
+		q.recorder.resize(*size)
+	}
+	return size
+}
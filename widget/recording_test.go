@@ -0,0 +1,89 @@
+package widget
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCastRecorderWrite(t *testing.T) {
+	tests := []struct {
+		name   string
+		writes []string
+		want   []string
+	}{
+		{
+			name:   "ascii",
+			writes: []string{"hello", " world"},
+			want:   []string{"hello", " world"},
+		},
+		{
+			name:   "complete runes",
+			writes: []string{"größe €"},
+			want:   []string{"größe €"},
+		},
+		{
+			name:   "rune split across writes",
+			writes: []string{"a\xe2\x82", "\xacb"},
+			want:   []string{"a", "€b"},
+		},
+		{
+			name:   "rune split byte by byte",
+			writes: []string{"\xf0", "\x9f", "\x98", "\x80"},
+			want:   []string{"😀"},
+		},
+		{
+			name:   "incomplete rune flushed on close",
+			writes: []string{"a\xe2"},
+			want:   []string{"a", "�"},
+		},
+		{
+			name:   "invalid bytes aren't held back",
+			writes: []string{"a\xff", "b"},
+			want:   []string{"a�", "b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := filepath.Join(t.TempDir(), "test.cast")
+			file, err := os.Create(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := &castRecorder{file: file, start: time.Now()}
+			for _, w := range tt.writes {
+				if n, err := r.Write([]byte(w)); n != len(w) || err != nil {
+					t.Fatalf("Write() = %d, %v, want %d, nil", n, err, len(w))
+				}
+			}
+			if err := r.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			f, err := os.Open(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			var got []string
+			scanner := bufio.NewScanner(f)
+			for scanner.Scan() {
+				var event []any
+				if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+					t.Fatalf("invalid event %q: %v", scanner.Text(), err)
+				}
+				if len(event) != 3 || event[1] != "o" {
+					t.Fatalf("unexpected event %v", event)
+				}
+				got = append(got, event[2].(string))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("events = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -5,136 +5,397 @@ package widget
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"os"
-	"time"
+	"slices"
+	"strings"
+	"syscall"
 
 	"github.com/creack/pty"
 	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
 	"github.com/diamondburned/gotk4/pkg/glib/v2"
 	"github.com/diamondburned/gotk4/pkg/gtk/v4"
 	"github.com/getseabird/seabird/api"
 	"github.com/jgillich/gotk4-vte/pkg/vte/v3"
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/util/httpstream"
-	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/tools/remotecommand"
 )
 
 type TerminalPage struct {
 	*adw.NavigationPage
-	pty *os.File
+	ctx       context.Context
+	cluster   *api.Cluster
+	pod       *corev1.Pod
+	container string
+	terminal  *vte.Terminal
+	stack     *gtk.Stack
+	status    *adw.StatusPage
+	cancel    context.CancelFunc
 }
 
-func NewTerminalPage(ctx context.Context, cluster *api.Cluster, pod *corev1.Pod, container string) (w *TerminalPage) {
+// NewTerminalPage opens a terminal with the first shell found in the
+// container.
+func NewTerminalPage(ctx context.Context, cluster *api.Cluster, pod *corev1.Pod, container string) *TerminalPage {
+	return NewTerminalPageWithCommand(ctx, cluster, pod, container, nil)
+}
+
+// NewTerminalPageWithCommand opens a terminal running the command. Without a
+// command, a shell is detected.
+func NewTerminalPageWithCommand(ctx context.Context, cluster *api.Cluster, pod *corev1.Pod, container string, command []string) *TerminalPage {
+	p, header := newTerminalPage(ctx, cluster, pod, container)
+
+	entry := gtk.NewEntry()
+	entry.SetPlaceholderText("Command (leave empty to detect a shell)")
+	entry.SetText(strings.Join(command, " "))
+	entry.SetHExpand(true)
+	entry.ConnectActivate(func() {
+		entry.RemoveCSSClass("error")
+		text := strings.TrimSpace(entry.Text())
+		if text == "" {
+			p.connect(nil)
+			return
+		}
+		command, err := splitCommand(text)
+		if err != nil {
+			entry.AddCSSClass("error")
+			return
+		}
+		prefs := cluster.ClusterPreferences.Value()
+		prefs.AddCommandHistory(commandHistoryKey(pod, container), text)
+		cluster.ClusterPreferences.Pub(prefs)
+		p.connect(command)
+	})
+	clamp := adw.NewClamp()
+	clamp.SetChild(entry)
+	header.SetTitleWidget(clamp)
+	header.PackEnd(p.createHistory(entry))
+
+	p.connect(command)
+
+	return p
+}
+
+// NewAttachPage attaches the terminal to the main process of the container.
+// Detaching leaves the process running.
+func NewAttachPage(ctx context.Context, cluster *api.Cluster, pod *corev1.Pod, container string) *TerminalPage {
+	p, header := newTerminalPage(ctx, cluster, pod, container)
+
+	title := adw.NewWindowTitle(container, "Attached")
+	header.SetTitleWidget(title)
+
+	detach := gtk.NewButtonWithLabel("Detach")
+	detach.AddCSSClass("flat")
+	detach.SetTooltipText("Disconnect without stopping the process")
+	detach.ConnectClicked(func() {
+		if p.cancel != nil {
+			p.cancel()
+		}
+		p.showStatus("Detached", "The process keeps running in the container.")
+	})
+	header.PackEnd(detach)
+
+	attach := gtk.NewButtonWithLabel("Attach Again")
+	attach.SetHAlign(gtk.AlignCenter)
+	attach.AddCSSClass("pill")
+	attach.AddCSSClass("suggested-action")
+	attach.ConnectClicked(p.attach)
+	p.status.SetChild(attach)
+
+	p.attach()
+
+	return p
+}
+
+func newTerminalPage(ctx context.Context, cluster *api.Cluster, pod *corev1.Pod, container string) (*TerminalPage, *adw.HeaderBar) {
 	box := gtk.NewBox(gtk.OrientationVertical, 0)
 	nav := adw.NewNavigationPage(box, container)
-	w = &TerminalPage{NavigationPage: nav}
+	p := &TerminalPage{NavigationPage: nav, cluster: cluster, pod: pod, container: container}
 
 	ctx, cancel := context.WithCancel(ctx)
 	nav.ConnectHidden(cancel)
+	p.ctx = ctx
 
 	header := adw.NewHeaderBar()
 	header.AddCSSClass("flat")
 	header.SetShowStartTitleButtons(false)
 	box.Append(header)
 
-	terminal := vte.NewTerminal()
-	terminal.SetHExpand(true)
-	terminal.SetVExpand(true)
-	box.Append(terminal)
-
-	// TODO detect size changes and forward
-	// sizeQueue := make(sizeQueue, 1)
-	// go func() {
-	// 	<-ctx.Done()
-	// 	close(sizeQueue)
-	// }()
-	// sizeQueue <- remotecommand.TerminalSize{Width: uint16(terminal.Width()), Height: uint16(terminal.Height())}
-	// parent.NotifyProperty("default-width", func() {
-	// 	size := remotecommand.TerminalSize{Width: uint16(terminal.Width()), Height: uint16(terminal.Height())}
-	// 	select {
-	// 	case sizeQueue.ch <- size:
-	// 	default:
-	// 	}
-	// })
+	p.terminal = vte.NewTerminal()
+	p.terminal.SetHExpand(true)
+	p.terminal.SetVExpand(true)
+
+	p.status = adw.NewStatusPage()
+	p.status.SetIconName("terminal-symbolic")
+	p.status.SetVExpand(true)
+
+	p.stack = gtk.NewStack()
+	p.stack.AddNamed(p.terminal, "terminal")
+	p.stack.AddNamed(p.status, "status")
+	box.Append(p.stack)
+
+	return p, header
+}
+
+// createHistory returns a button listing the commands recently run in this
+// container.
+func (p *TerminalPage) createHistory(entry *gtk.Entry) *gtk.MenuButton {
+	list := gtk.NewListBox()
+	list.AddCSSClass("navigation-sidebar")
+	list.SetSelectionMode(gtk.SelectionNone)
+
+	popover := gtk.NewPopover()
+	popover.SetChild(list)
+	popover.ConnectShow(func() {
+		list.RemoveAll()
+		commands := p.cluster.ClusterPreferences.Value().Terminal.History[commandHistoryKey(p.pod, p.container)]
+		if len(commands) == 0 {
+			label := gtk.NewLabel("No recent commands")
+			label.AddCSSClass("dim-label")
+			list.Append(label)
+		}
+		for _, command := range commands {
+			label := gtk.NewLabel(command)
+			label.SetHAlign(gtk.AlignStart)
+			list.Append(label)
+		}
+	})
+	list.ConnectRowActivated(func(row *gtk.ListBoxRow) {
+		label, ok := row.Child().(*gtk.Label)
+		if !ok {
+			return
+		}
+		popover.Popdown()
+		entry.SetText(label.Text())
+		entry.Activate()
+	})
+
+	button := gtk.NewMenuButton()
+	button.SetIconName("document-open-recent-symbolic")
+	button.SetTooltipText("Recent commands")
+	button.SetPopover(popover)
+	return button
+}
+
+// session ends the current session and prepares the terminal for a new one.
+func (p *TerminalPage) session() (context.Context, *os.File, *sizeQueue, error) {
+	if p.cancel != nil {
+		p.cancel()
+	}
+	ctx, cancel := context.WithCancel(p.ctx)
+	p.cancel = cancel
+
+	p.terminal.Reset(true, true)
+	p.stack.SetVisibleChildName("terminal")
+
+	tty, err := openTerminalPty(ctx, p.terminal)
+	if err != nil {
+		return ctx, nil, nil, err
+	}
+	return ctx, tty, newTerminalSizeQueue(ctx, p.terminal), nil
+}
+
+// attach connects to the main process of the container. Without a TTY in the
+// container spec, its output is shown as is.
+func (p *TerminalPage) attach() {
+	ctx, tty, sizeQueue, err := p.session()
+	if err != nil {
+		ShowErrorDialog(ctx, "Unable to open pty", err)
+		return
+	}
+
+	var spec *corev1.Container
+	for _, c := range slices.Concat(p.pod.Spec.Containers, p.pod.Spec.InitContainers) {
+		if c.Name == p.container {
+			spec = &c
+		}
+	}
+	for _, c := range p.pod.Spec.EphemeralContainers {
+		if c.Name == p.container {
+			container := corev1.Container(c.EphemeralContainerCommon)
+			spec = &container
+		}
+	}
+	if spec == nil {
+		tty.Close()
+		p.showStatus("Container Not Found", fmt.Sprintf("%s does not exist in the pod.", p.container))
+		return
+	}
+
+	var stdin io.Reader
+	if spec.Stdin {
+		stdin = tty
+	}
+	if spec.TTY {
+		p.terminal.Feed("If you don't see a command prompt, try pressing enter.\r\n")
+	}
 
 	go func() {
-		pty, tty, err := pty.Open()
+		defer tty.Close()
+		stdout, sizeQueue, err := p.record(ctx, "attach", tty, sizeQueue)
 		if err != nil {
-			ShowErrorDialog(ctx, "Unable to open pty", err)
+			glib.IdleAdd(func() {
+				ShowErrorDialog(ctx, "Recording failed", err)
+			})
 			return
 		}
+		err = podAttach(ctx, p.cluster, p.pod, p.container, stdin, stdout, stdout, spec.TTY, sizeQueue)
+		if errors.Is(err, context.Canceled) || ctx.Err() != nil {
+			return
+		}
+		glib.IdleAdd(func() {
+			if err != nil {
+				ShowErrorDialog(ctx, "Attach failed", err)
+			}
+			p.showStatus("Session Ended", "The process closed the connection or exited.")
+		})
+	}()
+}
+
+// connect ends the current session and runs the command in a new one. Without
+// a command, the first shell found in the container is started.
+func (p *TerminalPage) connect(command []string) {
+	ctx, tty, sizeQueue, err := p.session()
+	if err != nil {
+		ShowErrorDialog(ctx, "Unable to open pty", err)
+		return
+	}
+	shells := p.cluster.ClusterPreferences.Value().Terminal.Shells
+
+	go func() {
 		defer tty.Close()
 
-		go func() {
-			// TODO there is a race condition here, not sure why
-			time.Sleep(500 * time.Millisecond)
+		if command == nil {
+			shell, err := detectShell(ctx, p.cluster, p.pod, p.container, shells)
+			if err != nil {
+				glib.IdleAdd(func() {
+					if errors.Is(err, errNoShell) {
+						p.showStatus("No Shell Found", fmt.Sprintf("None of %s exist in the container. Enter a command to run instead, or change the shells in the cluster preferences.", strings.Join(shells, ", ")))
+					} else if !errors.Is(err, context.Canceled) {
+						ShowErrorDialog(ctx, "Exec failed", err)
+					}
+				})
+				return
+			}
+			command = []string{shell}
+		}
+
+		stdout, sizeQueue, err := p.record(ctx, strings.Join(command, " "), tty, sizeQueue)
+		if err != nil {
 			glib.IdleAdd(func() {
-				vtePty, err := vte.NewPtyForeignSync(ctx, int(pty.Fd()))
-				if err != nil {
-					ShowErrorDialog(ctx, "Unable to open pty", err)
-					return
-				}
-				terminal.SetPty(vtePty)
+				ShowErrorDialog(ctx, "Recording failed", err)
 			})
-		}()
-
-		if err := podExec(ctx, cluster, pod, container, []string{"/bin/sh"}, tty, tty, tty, nil); err != nil {
+			return
+		}
+		if err := podExec(ctx, p.cluster, p.pod, p.container, command, tty, stdout, nil, true, sizeQueue); err != nil {
 			if !errors.Is(err, context.Canceled) {
 				glib.IdleAdd(func() {
+					if isCommandNotFound(err) {
+						p.showStatus("Command Not Found", fmt.Sprintf("%s does not exist in the container.", command[0]))
+						return
+					}
 					ShowErrorDialog(ctx, "Exec failed", err)
 				})
 			}
 		}
 	}()
-
-	return
 }
 
-type sizeQueue chan remotecommand.TerminalSize
-
-func (s *sizeQueue) Next() *remotecommand.TerminalSize {
-	size, ok := <-*s
-	if !ok {
-		return nil
+// record starts a recording if enabled for the cluster and returns the
+// streams to use for the session. The recording ends with ctx. Sessions are
+// not started if the recording fails, as it may be required for auditing.
+func (p *TerminalPage) record(ctx context.Context, command string, stdout io.Writer, sizeQueue *sizeQueue) (io.Writer, remotecommand.TerminalSizeQueue, error) {
+	recorder, err := startRecording(ctx, p.cluster, p.pod, p.container, command, sizeQueue.initial)
+	if err != nil {
+		return nil, nil, err
 	}
-	return &size
+	if recorder == nil {
+		return stdout, sizeQueue, nil
+	}
+	go func() {
+		<-ctx.Done()
+		recorder.Close()
+	}()
+	return io.MultiWriter(stdout, recorder), &recordingSizeQueue{TerminalSizeQueue: sizeQueue, recorder: recorder}, nil
 }
 
-func podExec(ctx context.Context, cluster *api.Cluster, pod *corev1.Pod, container string, command []string, stdin io.Reader, stdout io.Writer, stderr io.Writer, sizeQueue remotecommand.TerminalSizeQueue) error {
-	req := cluster.CoreV1().RESTClient().Post().Resource("pods").Name(pod.Name).Namespace(pod.Namespace).SubResource("exec")
-	option := &corev1.PodExecOptions{
-		Container: container,
-		Command:   command,
-		Stdin:     true,
-		Stdout:    true,
-		Stderr:    true,
-		TTY:       true,
-	}
-	req.VersionedParams(
-		option,
-		scheme.ParameterCodec,
-	)
-
-	spdy, err := remotecommand.NewSPDYExecutor(cluster.Config, "POST", req.URL())
+func (p *TerminalPage) showStatus(title, description string) {
+	p.status.SetTitle(title)
+	p.status.SetDescription(description)
+	p.stack.SetVisibleChildName("status")
+}
+
+// openTerminalPty connects a new pty to the terminal and returns its tty end.
+// VTE takes ownership of a duplicate of the pty fd, so the Go file can't be
+// closed by the garbage collector while the terminal still uses it.
+func openTerminalPty(ctx context.Context, terminal *vte.Terminal) (*os.File, error) {
+	ptmx, tty, err := pty.Open()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	ws, err := remotecommand.NewWebSocketExecutor(cluster.Config, "GET", req.URL().String())
+	defer ptmx.Close()
+
+	fd, err := syscall.Dup(int(ptmx.Fd()))
 	if err != nil {
-		return err
+		tty.Close()
+		return nil, err
 	}
-	exec, err := remotecommand.NewFallbackExecutor(ws, spdy, httpstream.IsUpgradeFailure)
+	vtePty, err := vte.NewPtyForeignSync(ctx, fd)
 	if err != nil {
-		return err
+		syscall.Close(fd)
+		tty.Close()
+		return nil, err
 	}
+	terminal.SetPty(vtePty)
 
-	return exec.StreamWithContext(ctx, remotecommand.StreamOptions{
-		Stdin:             stdin,
-		Stdout:            stdout,
-		Stderr:            stderr,
-		Tty:               true,
-		TerminalSizeQueue: sizeQueue,
+	return tty, nil
+}
+
+// sizeQueue forwards the terminal size to the remote TTY. Only the most recent
+// size is kept.
+type sizeQueue struct {
+	ctx     context.Context
+	ch      chan remotecommand.TerminalSize
+	initial remotecommand.TerminalSize
+}
+
+// newTerminalSizeQueue returns a queue that starts with the current size of
+// the terminal and receives its size changes. It must be called on the main
+// loop.
+func newTerminalSizeQueue(ctx context.Context, terminal *vte.Terminal) *sizeQueue {
+	s := &sizeQueue{ctx: ctx, ch: make(chan remotecommand.TerminalSize, 1)}
+
+	var last remotecommand.TerminalSize
+	update := func() {
+		size := remotecommand.TerminalSize{Width: uint16(terminal.ColumnCount()), Height: uint16(terminal.RowCount())}
+		if size == last {
+			return
+		}
+		last = size
+		select {
+		case <-s.ch:
+		default:
+		}
+		s.ch <- size
+	}
+	update()
+	s.initial = last
+	// VTE has no signal for grid size changes, so check on every frame
+	// while the terminal is shown.
+	terminal.AddTickCallback(func(gtk.Widgetter, gdk.FrameClocker) bool {
+		update()
+		return ctx.Err() == nil
 	})
+
+	return s
+}
+
+func (s *sizeQueue) Next() *remotecommand.TerminalSize {
+	select {
+	case size := <-s.ch:
+		return &size
+	case <-s.ctx.Done():
+		return nil
+	}
 }
@@ -49,6 +49,14 @@ func NewTerminalPage(ctx context.Context, cluster *api.Cluster, pod *corev1.Pod,
 	return nil
 }
 
+func NewTerminalPageWithCommand(ctx context.Context, cluster *api.Cluster, pod *corev1.Pod, container string, command []string) *TerminalPage {
+	return nil
+}
+
+func NewAttachPage(ctx context.Context, cluster *api.Cluster, pod *corev1.Pod, container string) *TerminalPage {
+	return nil
+}
+
 // func server() (int, error) {
 // 	http.HandleFunc("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 // 		c, err := websocket.Accept(w, r, nil)